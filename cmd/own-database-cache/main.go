@@ -2,33 +2,118 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
 	"own-database-cache/internal/app"
 	"own-database-cache/internal/config"
 	"own-database-cache/internal/datasource/cache"
 	"own-database-cache/internal/datasource/database"
-	"log"
-	"os"
-	"os/signal"
+	"own-database-cache/internal/logging"
+	pkg "own-database-cache/pkg/cache"
+	"time"
 )
 
 func main() {
+	configPath := flag.String("config", "", "read the configuration from `path`, a JSON, YAML or TOML file (default config.json, config.yaml or config.toml found from the working directory up)")
+	hotKeys := flag.Int("hotkeys", 0, "report the `N` most accessed and largest cache keys after the run")
+	overrides := make(map[string]string)
+	for _, field := range config.Fields() {
+		name := field.Name
+		usage := fmt.Sprintf("override %s, also set by %s (built-in default %q)", name, field.Env, field.Default)
+		flag.Func(name, usage, func(value string) error {
+			overrides[name] = value
+			return nil
+		})
+	}
+	flag.Parse()
+
+	// Until the config is read, errors are logged as text.
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	config, err := config.LoadConfig("config.json")
+	if *configPath == "" {
+		path, err := config.Find(".")
+		if err != nil {
+			fatal(logger, "failed to find the config file", err)
+		}
+		*configPath = path
+	}
+	cfg, err := config.Load(*configPath, overrides)
 	if err != nil {
-		log.Fatalf("Error reading config: %v", err)
+		fatal(logger, "failed to read the config", err)
+	}
+	if err := cfg.CheckPaths(); err != nil {
+		fatal(logger, "failed to read the config", err)
 	}
 
-	cacheFile := config.PathConfig.CacheFilePath
-	databaseFile := config.PathConfig.DatabaseFilePath
-	fileName := config.PathConfig.FileName
+	logLevel, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		fatal(logger, "failed to read the config", err)
+	}
+	level := new(slog.LevelVar)
+	level.Set(logLevel)
+	if logger, err = logging.New(os.Stderr, cfg.LogFormat, level); err != nil {
+		fatal(logger, "failed to read the config", err)
+	}
+	slog.SetDefault(logger)
+
+	cacheClient, err := cache.NewClientFromConfig(cfg, cache.WithLogger(logger))
+	if err != nil {
+		fatal(logger, "failed to read the config", err)
+	}
+	databaseClient := database.NewClientFromConfig(cfg, database.WithLogger(logger))
+	databaseClient.Database().OnSlowQuery(time.Duration(cfg.SlowQueryMillis)*time.Millisecond, func(query string, took time.Duration) {
+		logger.Warn("slow query", "query", query, "took", took)
+	})
 
-	cacheClient := cache.NewClient(cacheFile + fileName)
-	databaseClient := database.NewClient(databaseFile)
+	watcher := config.NewWatcher(cfg, config.WatcherConfig{
+		Path:      *configPath,
+		Overrides: overrides,
+		OnReload:  app.Reload(logger, level, cacheClient, databaseClient),
+		OnError: func(err error) {
+			logger.Error("failed to reload the config", "error", err)
+		},
+	})
+	go watcher.Run(ctx)
+
+	var tracker *pkg.Tracker
+	if *hotKeys > 0 {
+		tracker = cacheClient.EnableTracking(pkg.TrackerConfig{SampleRate: 1, Window: time.Minute})
+	}
+
+	app.Invalidate(logger, cfg, cacheClient, databaseClient)
+
+	if err := app.WarmUp(ctx, logger, cfg, cacheClient, databaseClient); err != nil {
+		logger.Error("warm-up failed", "error", err)
+	}
+
+	if err := app.Process(ctx, logger, cfg, cacheClient, databaseClient); err != nil {
+		logger.Error("processing failed", "error", err)
+	}
+
+	if tracker != nil {
+		printKeyReport(tracker, *hotKeys)
+	}
+}
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func printKeyReport(tracker *pkg.Tracker, n int) {
+	fmt.Println("Hot keys:")
+	for _, stat := range tracker.HotKeys(n) {
+		fmt.Printf("  %-40s %8d hits\n", stat.Key, stat.Hits)
+	}
 
-	if err := app.Process(ctx, cacheClient, databaseClient); err != nil {
-		fmt.Println("Error:", err)
+	fmt.Println("Large keys:")
+	for _, stat := range tracker.LargeKeys(n) {
+		fmt.Printf("  %-40s %8d bytes\n", stat.Key, stat.Size)
 	}
 }
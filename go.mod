@@ -1,5 +1,5 @@
 module own-database-cache
 
-go 1.20
+go 1.21
 
 require github.com/golang/mock v1.6.0
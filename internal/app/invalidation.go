@@ -0,0 +1,31 @@
+package app
+
+import (
+	"log/slog"
+	"own-database-cache/internal/config"
+	"own-database-cache/internal/datasource/cache"
+	"own-database-cache/internal/datasource/database"
+	pkg "own-database-cache/pkg/cache"
+	db "own-database-cache/pkg/database"
+	"time"
+)
+
+// Invalidate keeps the cache in line with the rows the database client changes.
+func Invalidate(logger *slog.Logger, cfg *config.Config, cacheClient *cache.Client, databaseClient *database.Client) {
+	invalidation := cfg.Invalidation
+	if !invalidation.Enabled {
+		return
+	}
+
+	invalidator := cacheClient.NewInvalidator(pkg.InvalidatorConfig{
+		Table:           invalidation.Table,
+		KeyColumn:       invalidation.KeyColumn,
+		ValueColumn:     invalidation.ValueColumn,
+		ExpiresAtColumn: invalidation.ExpiresAtColumn,
+		Expiration:      time.Duration(cfg.ExpirationTimeCache) * time.Second,
+		OnError: func(change db.Change, err error) {
+			logger.Error("invalidation failed", "table", change.Table, "operation", change.Operation, "error", err)
+		},
+	})
+	invalidator.Subscribe(databaseClient.Database())
+}
@@ -4,69 +4,100 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"own-database-cache/internal/config"
-	"own-database-cache/internal/datasource/cache"
-	"own-database-cache/internal/datasource/database"
+	"own-database-cache/internal/controller"
+	"own-database-cache/internal/datasource"
 	"time"
 )
 
-func Process(ctx context.Context, cacheClient *cache.Client, databaseClient *database.Client) error {
-	config, err := config.LoadConfig("config.json")
-	if err != nil {
-		return fmt.Errorf("Error reading config: %v", err)
+// expirationPolicy is implemented by caches that pick the expiration of keys set without one.
+type expirationPolicy interface {
+	MaxExpiration(key string) time.Duration
+}
+
+func Process(ctx context.Context, logger *slog.Logger, config *config.Config, cacheClient, databaseClient datasource.Datasource) error {
+	const key = "user:12345:profile"
+	cacheTTL := time.Duration(config.ExpirationTimeCache) * time.Second
+
+	// The cached copy expires when the TTL policy of the cache says, jitter and reloads
+	// included, and expiry is tracked in whole seconds.
+	expiration := cacheTTL
+	if policy, ok := cacheClient.(expirationPolicy); ok {
+		expiration = policy.MaxExpiration(key)
 	}
 
-	expirationTimeCache := time.Duration(config.ExpirationTimeCache) * time.Second
+	return run(ctx, logger, cacheClient, databaseClient, scenario{
+		key:      key,
+		value:    "best user, expired after 5 seconds",
+		cacheTTL: cacheTTL,
+		wait:     expiration + 2*time.Second,
+	})
+}
 
-	bestUser := "best user, expired after 5 seconds"
-	key := "user:12345:profile"
+// scenario writes value through the layered client, waits for the cached copy to
+// expire after wait and expects the next read to restore it from the database.
+type scenario struct {
+	key      string
+	value    string
+	cacheTTL time.Duration
+	wait     time.Duration
+}
 
-	if err := databaseClient.Set(ctx, key, bestUser, expirationTimeCache); err != nil {
-		return fmt.Errorf("database Set error: %w", err)
-	}
+func run(ctx context.Context, logger *slog.Logger, cacheClient, databaseClient datasource.Datasource, s scenario) error {
+	source := datasource.Chain(databaseClient,
+		datasource.WithCircuitBreaker(datasource.CircuitBreakerConfig{}),
+		datasource.WithRetry(datasource.DefaultRetryPolicy),
+	)
+	client := controller.NewLayeredClient(cacheClient, source, datasource.LayeredConfig{
+		Mode:       datasource.CacheAside,
+		CacheTTL:   s.cacheTTL,
+		ServeStale: true,
+		Logger:     logger,
+	})
+	defer client.Close(ctx)
 
-	if err := cacheClient.Set(ctx, key, bestUser, 5*time.Second); err != nil {
-		return fmt.Errorf("cache Set error: %w", err)
+	if err := controller.Set(ctx, client, s.key, s.value, 0); err != nil {
+		return fmt.Errorf("layered Set error: %w", err)
 	}
 
-	got, err := cacheClient.Get(ctx, key)
-	if err != nil {
+	var got string
+	if err := datasource.GetInto(ctx, cacheClient, s.key, &got); err != nil {
 		return err
 	}
-	if !checkValue(got, bestUser) {
+	if got != s.value {
 		return errors.New("cached value does not match")
 	}
 
 	select {
-	case <-time.After(7 * time.Second):
+	case <-time.After(s.wait):
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 
-	got, err = cacheClient.Get(ctx, key)
-	if err != nil {
-		return err
-	}
-	if got != nil {
+	_, err := cacheClient.Get(ctx, s.key)
+	if err == nil {
 		return errors.New("unexpected cache hit: data should have expired")
 	}
+	if !errors.Is(err, datasource.ErrNotFound) {
+		return err
+	}
 
-	gotAgain, err := databaseClient.Get(ctx, key)
+	gotAgain, err := controller.Get[string](ctx, client, s.key)
 	if err != nil {
 		return err
 	}
-	if !checkValue(gotAgain, bestUser) {
+	if gotAgain != s.value {
 		return errors.New("database value does not match")
 	}
 
-	fmt.Println("Success!")
-	return nil
-}
-
-func checkValue(got any, want string) bool {
-	gotStr, ok := got.(string)
-	if !ok {
-		return false
+	if err := datasource.GetInto(ctx, cacheClient, s.key, &got); err != nil {
+		return err
+	}
+	if got != s.value {
+		return errors.New("cache was not repopulated from the database")
 	}
-	return gotStr == want
+
+	logger.Info("scenario passed", "key", s.key)
+	return nil
 }
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"own-database-cache/internal/datasource"
+	"own-database-cache/internal/datasource/mocks"
+)
+
+const (
+	testKey   = "user:1:profile"
+	testValue = "best user"
+	testTTL   = 5 * time.Second
+)
+
+func newScenario() scenario {
+	return scenario{key: testKey, value: testValue, cacheTTL: testTTL}
+}
+
+func TestRunRestoresExpiredValueFromDatabase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cache := mocks.NewMockDatasource(ctrl)
+	database := mocks.NewMockDatasource(ctrl)
+	ctx := gomock.Any()
+
+	gomock.InOrder(
+		database.EXPECT().Set(ctx, testKey, testValue, time.Duration(0)).Return(nil),
+		cache.EXPECT().Set(ctx, testKey, testValue, time.Duration(0)).Return(nil),
+		cache.EXPECT().Get(ctx, testKey).Return(testValue, nil),
+		// The cached copy has expired.
+		cache.EXPECT().Get(ctx, testKey).Return(nil, datasource.ErrNotFound),
+		// The layered read misses the cache and copies the database value back.
+		cache.EXPECT().Get(ctx, testKey).Return(nil, datasource.ErrNotFound),
+		database.EXPECT().Get(ctx, testKey).Return(testValue, nil),
+		cache.EXPECT().Set(ctx, testKey, testValue, testTTL).Return(nil),
+		cache.EXPECT().Get(ctx, testKey).Return(testValue, nil),
+	)
+
+	if err := run(context.Background(), slog.Default(), cache, database, newScenario()); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+}
+
+func TestRunFailsOnCacheMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cache := mocks.NewMockDatasource(ctrl)
+	database := mocks.NewMockDatasource(ctrl)
+
+	database.EXPECT().Set(gomock.Any(), testKey, testValue, time.Duration(0)).Return(nil)
+	cache.EXPECT().Set(gomock.Any(), testKey, testValue, time.Duration(0)).Return(nil)
+	cache.EXPECT().Get(gomock.Any(), testKey).Return("someone else", nil)
+
+	if err := run(context.Background(), slog.Default(), cache, database, newScenario()); err == nil {
+		t.Fatal("run() should fail when the cached value differs")
+	}
+}
+
+func TestRunRetriesFailingDatabase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cache := mocks.NewMockDatasource(ctrl)
+	database := mocks.NewMockDatasource(ctrl)
+	diskErr := errors.New("disk is full")
+
+	database.EXPECT().Set(gomock.Any(), testKey, testValue, time.Duration(0)).
+		Return(diskErr).Times(datasource.DefaultRetryPolicy.MaxAttempts)
+
+	err := run(context.Background(), slog.Default(), cache, database, newScenario())
+	if !errors.Is(err, diskErr) {
+		t.Fatalf("run() error = %v, want %v", err, diskErr)
+	}
+}
+
+func TestRunStopsOnCanceledContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cache := mocks.NewMockDatasource(ctrl)
+	database := mocks.NewMockDatasource(ctrl)
+
+	database.EXPECT().Set(gomock.Any(), testKey, testValue, time.Duration(0)).Return(nil)
+	cache.EXPECT().Set(gomock.Any(), testKey, testValue, time.Duration(0)).Return(nil)
+	cache.EXPECT().Get(gomock.Any(), testKey).Return(testValue, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s := newScenario()
+	s.wait = time.Hour
+
+	if err := run(ctx, slog.Default(), cache, database, s); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
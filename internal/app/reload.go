@@ -0,0 +1,35 @@
+package app
+
+import (
+	"log/slog"
+	"own-database-cache/internal/config"
+	"own-database-cache/internal/datasource/cache"
+	"own-database-cache/internal/datasource/database"
+	"own-database-cache/internal/logging"
+	"time"
+)
+
+// Reload returns the config watcher callback applying the reloadable settings to the
+// running clients and level, the level of the logs, and logging what changed.
+func Reload(logger *slog.Logger, level *slog.LevelVar, cacheClient *cache.Client, databaseClient *database.Client) func(config.Reload) error {
+	return func(reload config.Reload) error {
+		cfg := reload.Config
+		logLevel, err := logging.ParseLevel(cfg.LogLevel)
+		if err != nil {
+			return err
+		}
+		if err := cacheClient.Reload(cfg); err != nil {
+			return err
+		}
+		level.Set(logLevel)
+		databaseClient.Database().SetSlowQueryThreshold(time.Duration(cfg.SlowQueryMillis) * time.Millisecond)
+
+		for _, change := range reload.Applied {
+			logger.Info("config changed", "field", change.Name, "old", change.Old, "new", change.New)
+		}
+		for _, change := range reload.RestartRequired {
+			logger.Warn("config changed, restart to apply it", "field", change.Name, "old", change.Old, "new", change.New)
+		}
+		return nil
+	}
+}
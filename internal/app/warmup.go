@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"own-database-cache/internal/config"
+	"own-database-cache/internal/datasource/cache"
+	"own-database-cache/internal/datasource/database"
+	pkg "own-database-cache/pkg/cache"
+	"time"
+)
+
+func WarmUp(ctx context.Context, logger *slog.Logger, cfg *config.Config, cacheClient *cache.Client, databaseClient *database.Client) error {
+	warmup := cfg.Warmup
+	if !warmup.Enabled {
+		return nil
+	}
+
+	warmer := cacheClient.NewWarmer(databaseClient.Database(), pkg.WarmerConfig{
+		Concurrency:     warmup.Concurrency,
+		ExpiresAtColumn: warmup.ExpiresAtColumn,
+		Expiration:      time.Duration(cfg.ExpirationTimeCache) * time.Second,
+		Progress: func(done, total int) {
+			logger.Debug("warm-up progress", "done", done, "total", total)
+		},
+	})
+
+	var (
+		stored int
+		err    error
+	)
+	if len(warmup.Keys) > 0 {
+		stored, err = warmer.WarmKeys(ctx, warmup.Table, warmup.KeyColumn, warmup.ValueColumn, warmup.Keys)
+	} else {
+		stored, err = warmer.WarmTable(ctx, warmup.Table, warmup.KeyColumn, warmup.ValueColumn)
+	}
+	if err != nil {
+		return fmt.Errorf("warm-up error: %w", err)
+	}
+
+	logger.Info("warm-up finished", "keys", stored)
+	return nil
+}
@@ -1,34 +1,188 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type PathConfig struct {
-	DatabaseFilePath     string `json:"databaseFilePath"`
-	CacheFilePath        string `json:"cacheFilePath"`
-	FileName             string `json:"fileName"`
-	TestDatabaseFilePath string `json:"testDatabaseFilePath"`
-	TestCacheFilePath    string `json:"testCacheFilePath"`
+	DatabaseFilePath string `json:"databaseFilePath"`
+	CacheFilePath    string `json:"cacheFilePath"`
+	FileName         string `json:"fileName"`
+}
+
+type WarmupConfig struct {
+	Enabled         bool     `json:"enabled"`
+	Table           string   `json:"table"`
+	KeyColumn       string   `json:"keyColumn"`
+	ValueColumn     string   `json:"valueColumn"`
+	ExpiresAtColumn string   `json:"expiresAtColumn"`
+	Keys            []string `json:"keys"`
+	Concurrency     int      `json:"concurrency"`
+}
+
+// InvalidationConfig drops the cached keys of rows changed in Table, or refreshes them
+// from ValueColumn when it is set. Refreshed keys expire with their row when
+// ExpiresAtColumn holds its unix expiry time.
+type InvalidationConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Table           string `json:"table"`
+	KeyColumn       string `json:"keyColumn"`
+	ValueColumn     string `json:"valueColumn"`
+	ExpiresAtColumn string `json:"expiresAtColumn"`
+}
+
+// TTLPolicy sets the default expiration of keys starting with Prefix ("user:*" or "user:").
+// Jitter randomizes it by the given fraction in both directions, 0.1 means ±10%.
+type TTLPolicy struct {
+	Prefix string  `json:"prefix"`
+	TTL    int     `json:"ttl"`
+	Jitter float64 `json:"jitter"`
+}
+
+// L1CacheConfig enables the in-memory cache in front of the persistent one.
+type L1CacheConfig struct {
+	Enabled    bool `json:"enabled"`
+	MaxEntries int  `json:"maxEntries"`
+	TTLMillis  int  `json:"ttlMillis"`
+}
+
+// CacheLimits bounds the live items of the cache, zero limits are not enforced.
+type CacheLimits struct {
+	MaxEntries int   `json:"maxEntries"`
+	MaxBytes   int64 `json:"maxBytes"`
 }
 
 type Config struct {
-	PathConfig          PathConfig `json:"path"`
-	ExpirationTimeCache int        `json:"expirationTimeCache"`
+	Profile             string             `json:"profile"`
+	PathConfig          PathConfig         `json:"path"`
+	ExpirationTimeCache int                `json:"expirationTimeCache"`
+	ExpirationJitter    float64            `json:"expirationJitter"`
+	TTLPolicies         []TTLPolicy        `json:"ttlPolicies"`
+	StaleGraceCache     int                `json:"staleGraceCache"`
+	L1Cache             L1CacheConfig      `json:"l1Cache"`
+	CacheLimits         CacheLimits        `json:"cacheLimits"`
+	LogLevel            string             `json:"logLevel"`
+	LogFormat           string             `json:"logFormat"`
+	SlowQueryMillis     int                `json:"slowQueryMillis"`
+	Warmup              WarmupConfig       `json:"warmup"`
+	Invalidation        InvalidationConfig `json:"invalidation"`
+}
+
+// Default returns the configuration used for the fields missing from the config file.
+func Default() *Config {
+	return &Config{
+		PathConfig: PathConfig{
+			DatabaseFilePath: "data/db/",
+			CacheFilePath:    "data/cache/",
+			FileName:         "file.csv",
+		},
+		ExpirationTimeCache: 60,
+		LogLevel:            "info",
+		LogFormat:           "text",
+		L1Cache: L1CacheConfig{
+			MaxEntries: 1024,
+			TTLMillis:  1000,
+		},
+		Warmup: WarmupConfig{
+			Table:           "file",
+			KeyColumn:       "key",
+			ValueColumn:     "value",
+			ExpiresAtColumn: "expiresAt",
+			Concurrency:     4,
+		},
+		Invalidation: InvalidationConfig{
+			Table:           "file",
+			KeyColumn:       "key",
+			ExpiresAtColumn: "expiresAt",
+		},
+	}
 }
 
 func LoadConfig(configPath string) (*Config, error) {
+	return Load(configPath, nil)
+}
+
+// Load reads the config file over the defaults, then applies the OWNDB_* environment
+// variables and finally overrides, both keyed by field name (see Fields), and
+// validates the result. The file is JSON, YAML or TOML after its extension, and the
+// section of its "profiles" table named by the profile field is applied over the rest.
+// Relative data directories are resolved against the directory of the file.
+func Load(configPath string, overrides map[string]string) (*Config, error) {
 	configFile, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
 
-	var config Config
-	err = json.Unmarshal(configFile, &config)
+	doc, err := decode(configPath, configFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	if err := applyProfile(doc, overrides, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+
+	config := Default()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+
+	if err := applyEnv(config, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	for name, value := range overrides {
+		if err := Set(config, name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(configPath))
 	if err != nil {
 		return nil, err
 	}
+	config.PathConfig.resolve(dir)
+	return config, nil
+}
+
+// decode reads a config file in the format named by its extension, JSON by default.
+func decode(configPath string, data []byte) (map[string]any, error) {
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yaml", ".yml":
+		return parseYAML(data)
+	case ".toml":
+		return parseTOML(data)
+	}
 
-	return &config, nil
+	var doc map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+	return doc, nil
+}
+
+func (p *PathConfig) resolve(dir string) {
+	for _, path := range []*string{&p.DatabaseFilePath, &p.CacheFilePath} {
+		if !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path) + "/"
+		}
+	}
 }
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	return writeConfigAs(t, "config.json", content)
+}
+
+func writeConfigAs(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadAppliesDefaults(t *testing.T) {
+	path := writeConfig(t, `{"staleGraceCache": 30}`)
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	want := Default()
+	want.StaleGraceCache = 30
+	want.PathConfig.resolve(filepath.Dir(path))
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("LoadConfig() = %+v, want %+v", config, want)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	path := writeConfig(t, `{"expirationTimeCache": 5, "path": {"fileName": "file.csv"}}`)
+	t.Setenv("OWNDB_EXPIRATION_TIME_CACHE", "10")
+	t.Setenv("OWNDB_PATH_FILE_NAME", "env.csv")
+	t.Setenv("OWNDB_WARMUP_KEYS", "a, b,")
+	t.Setenv("OWNDB_TTL_POLICIES", `[{"prefix": "session:", "ttl": 60}]`)
+	t.Setenv("OWNDB_L1_CACHE_ENABLED", "true")
+
+	config, err := Load(path, map[string]string{"path.fileName": "flag.csv"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if config.ExpirationTimeCache != 10 {
+		t.Fatalf("ExpirationTimeCache = %d, want the environment value", config.ExpirationTimeCache)
+	}
+	if config.PathConfig.FileName != "flag.csv" {
+		t.Fatalf("FileName = %q, want the flag to take precedence", config.PathConfig.FileName)
+	}
+	if !reflect.DeepEqual(config.Warmup.Keys, []string{"a", "b"}) {
+		t.Fatalf("Warmup.Keys = %q", config.Warmup.Keys)
+	}
+	if len(config.TTLPolicies) != 1 || config.TTLPolicies[0].TTL != 60 {
+		t.Fatalf("TTLPolicies = %+v", config.TTLPolicies)
+	}
+	if !config.L1Cache.Enabled {
+		t.Fatal("L1Cache.Enabled should be set from the environment")
+	}
+
+	if _, err := Load(path, map[string]string{"expirationTime": "1"}); err == nil || !strings.Contains(err.Error(), "unknown config field") {
+		t.Fatalf("Load() error = %v, want an unknown field", err)
+	}
+}
+
+func TestLoadValidates(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, `{"expirationTimeCache": 0, "path": {"databaseFilePath": "data/db"}, "ttlPolicies": [{"prefix": "", "ttl": 1}]}`))
+	if err == nil {
+		t.Fatal("LoadConfig() should reject an invalid config")
+	}
+	for _, field := range []string{"expirationTimeCache", "path.databaseFilePath", "ttlPolicies[0].prefix"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Fatalf("LoadConfig() error = %v, want it to name %s", err, field)
+		}
+	}
+
+	if _, err := LoadConfig(writeConfig(t, `{"expirationTimeCahce": 5}`)); err == nil || !strings.Contains(err.Error(), "expirationTimeCahce") {
+		t.Fatalf("LoadConfig() error = %v, want the unknown field", err)
+	}
+}
+
+func TestFieldsEnvNames(t *testing.T) {
+	envs := make(map[string]string)
+	for _, field := range Fields() {
+		envs[field.Name] = field.Env
+	}
+
+	for name, want := range map[string]string{
+		"expirationTimeCache":   "OWNDB_EXPIRATION_TIME_CACHE",
+		"path.databaseFilePath": "OWNDB_PATH_DATABASE_FILE_PATH",
+		"l1Cache.ttlMillis":     "OWNDB_L1_CACHE_TTL_MILLIS",
+		"warmup.keys":           "OWNDB_WARMUP_KEYS",
+	} {
+		if envs[name] != want {
+			t.Fatalf("Env of %s = %q, want %q", name, envs[name], want)
+		}
+	}
+}
+
+const yamlConfig = `
+# comments and blank lines are skipped
+path:
+  databaseFilePath: data/db/
+  fileName: "file.csv" # trailing comment
+expirationTimeCache: 5
+expirationJitter: 0.25
+ttlPolicies:
+  - prefix: "session:*"
+    ttl: 300
+    jitter: 0.1
+  - {prefix: 'user:', ttl: 60}
+warmup:
+  enabled: true
+  keys: [a, "b, c"]
+  concurrency: 2
+`
+
+const tomlConfig = `
+# comments and blank lines are skipped
+expirationTimeCache = 5
+expirationJitter = 0.25
+
+[path]
+databaseFilePath = "data/db/"
+fileName = 'file.csv' # trailing comment
+
+[[ttlPolicies]]
+prefix = "session:*"
+ttl = 300
+jitter = 0.1
+
+[[ttlPolicies]]
+prefix = "user:"
+ttl = 60
+
+[warmup]
+enabled = true
+keys = [
+  "a",
+  "b, c",
+]
+concurrency = 2
+`
+
+func TestLoadFormats(t *testing.T) {
+	want := Default()
+	want.ExpirationTimeCache = 5
+	want.ExpirationJitter = 0.25
+	want.TTLPolicies = []TTLPolicy{{Prefix: "session:*", TTL: 300, Jitter: 0.1}, {Prefix: "user:", TTL: 60}}
+	want.Warmup.Enabled = true
+	want.Warmup.Keys = []string{"a", "b, c"}
+	want.Warmup.Concurrency = 2
+
+	for name, content := range map[string]string{"config.yaml": yamlConfig, "config.toml": tomlConfig} {
+		path := writeConfigAs(t, name, content)
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig(%s) error = %v", name, err)
+		}
+
+		want := *want
+		want.PathConfig.resolve(filepath.Dir(path))
+		if !reflect.DeepEqual(config, &want) {
+			t.Fatalf("LoadConfig(%s) = %+v, want %+v", name, config, &want)
+		}
+	}
+
+	for name, content := range map[string]string{
+		"config.yaml": "path:\n  fileName: |\n    file.csv\n",
+		"config.toml": "[path]\nfileName = \"file.csv\"\n[path]\nfileName = \"other.csv\"\n",
+	} {
+		if _, err := LoadConfig(writeConfigAs(t, name, content)); err == nil || !strings.Contains(err.Error(), "line") {
+			t.Fatalf("LoadConfig(%s) error = %v, want the line of the error", name, err)
+		}
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	path := writeConfig(t, `{
+		"profile": "dev",
+		"expirationTimeCache": 5,
+		"path": {"databaseFilePath": "data/db/", "cacheFilePath": "/var/cache/"},
+		"profiles": {
+			"dev": {"logLevel": "debug"},
+			"test": {"path": {"databaseFilePath": "data/test/db/"}, "warmup": {"keys": ["a"]}}
+		}
+	}`)
+	dir := filepath.Dir(path)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.Profile != "dev" || config.LogLevel != "debug" {
+		t.Fatalf("Profile = %q, LogLevel = %q, want the dev profile of the file", config.Profile, config.LogLevel)
+	}
+
+	t.Setenv("OWNDB_PROFILE", "prod")
+	config, err = Load(path, map[string]string{"profile": "test"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if config.Profile != "test" || config.LogLevel != "info" || config.ExpirationTimeCache != 5 {
+		t.Fatalf("Load() = %+v, want the test profile over the base config", config)
+	}
+	if want := filepath.Join(dir, "data/test/db") + "/"; config.PathConfig.DatabaseFilePath != want {
+		t.Fatalf("DatabaseFilePath = %q, want %q", config.PathConfig.DatabaseFilePath, want)
+	}
+	if config.PathConfig.CacheFilePath != "/var/cache/" {
+		t.Fatalf("CacheFilePath = %q, want the absolute path kept", config.PathConfig.CacheFilePath)
+	}
+
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), `unknown profile "prod"`) {
+		t.Fatalf("LoadConfig() error = %v, want the unknown profile from the environment", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "pkg", "cache")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Find(nested); err == nil {
+		t.Fatal("Find() should fail without a config file")
+	}
+
+	want := filepath.Join(root, "config.toml")
+	if err := os.WriteFile(want, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Find(nested); err != nil || got != want {
+		t.Fatalf("Find() = %q, %v, want %q", got, err, want)
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const envPrefix = "OWNDB_"
+
+// Field is a config value that can be overridden, named after its path in the config
+// file such as "path.fileName".
+type Field struct {
+	Name string
+	// Env is the environment variable overriding the field, e.g. OWNDB_PATH_FILE_NAME.
+	Env     string
+	Default string
+}
+
+// Fields lists every field of Config. Lists of strings are written comma separated,
+// other lists as JSON.
+func Fields() []Field {
+	var fields []Field
+	walk(reflect.ValueOf(Default()).Elem(), "", func(name string, value reflect.Value) error {
+		fields = append(fields, Field{Name: name, Env: envName(name), Default: format(value)})
+		return nil
+	})
+	return fields
+}
+
+// Set parses value into the field of config called name.
+func Set(config *Config, name, value string) error {
+	found := false
+	err := walk(reflect.ValueOf(config).Elem(), "", func(fieldName string, field reflect.Value) error {
+		if fieldName != name {
+			return nil
+		}
+		found = true
+		return parse(name, field, value)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("unknown config field %q", name)
+	}
+	return nil
+}
+
+func applyEnv(config *Config, lookup func(string) (string, bool)) error {
+	return walk(reflect.ValueOf(config).Elem(), "", func(name string, field reflect.Value) error {
+		env := envName(name)
+		value, ok := lookup(env)
+		if !ok {
+			return nil
+		}
+		if err := parse(name, field, value); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		return nil
+	})
+}
+
+// walk calls fn with every field below v that is not a struct, named by the json tags on its path.
+func walk(v reflect.Value, prefix string, fn func(name string, field reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := tag
+		if prefix != "" {
+			name = prefix + "." + tag
+		}
+
+		field := v.Field(i)
+		var err error
+		if field.Kind() == reflect.Struct {
+			err = walk(field, name, fn)
+		} else {
+			err = fn(name, field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parse(name string, field reflect.Value, value string) error {
+	var err error
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(value, 10, 64); err == nil {
+			field.SetInt(n)
+		}
+	case reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(value, 64); err == nil {
+			field.SetFloat(f)
+		}
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(value); err == nil {
+			field.SetBool(b)
+		}
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String {
+			field.Set(reflect.ValueOf(splitList(value)))
+			return nil
+		}
+		target := reflect.New(field.Type())
+		if err = json.Unmarshal([]byte(value), target.Interface()); err == nil {
+			field.Set(target.Elem())
+		}
+	default:
+		err = fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: invalid value %q: %w", name, value, err)
+	}
+	return nil
+}
+
+func format(field reflect.Value) string {
+	switch {
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		return strings.Join(field.Interface().([]string), ",")
+	case field.Kind() == reflect.Slice:
+		data, _ := json.Marshal(field.Interface())
+		return string(data)
+	default:
+		return fmt.Sprint(field.Interface())
+	}
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envName turns "path.fileName" into OWNDB_PATH_FILE_NAME.
+func envName(name string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+
+	var prev rune
+	for _, r := range name {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+		prev = r
+	}
+	return b.String()
+}
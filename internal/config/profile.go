@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profiles are the sections of the "profiles" table of the config file.
+const (
+	ProfileDev  = "dev"
+	ProfileTest = "test"
+	ProfileProd = "prod"
+)
+
+// fileNames are the config files looked up by Find, in order of preference.
+var fileNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// Find returns the config file in dir or, failing that, in the closest of its parents holding one.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range fileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found", strings.Join(fileNames, ", "))
+		}
+		dir = parent
+	}
+}
+
+// LoadTest loads the test profile of the config file found from the working directory
+// up and creates its data directories, for the tests of the packages of this module.
+func LoadTest() (*Config, error) {
+	configPath, err := Find(".")
+	if err != nil {
+		return nil, err
+	}
+	config, err := Load(configPath, map[string]string{"profile": ProfileTest})
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range config.PathConfig.dataDirs() {
+		if err := os.MkdirAll(path.dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// applyProfile replaces the profiles table of doc with the selected profile merged over
+// doc. The profile is chosen by the override, the environment or the file, in that order.
+func applyProfile(doc map[string]any, overrides map[string]string, lookup func(string) (string, bool)) error {
+	var profiles map[string]any
+	if raw, ok := doc["profiles"]; ok {
+		if profiles, ok = raw.(map[string]any); !ok {
+			return errors.New("profiles: must be a table of profiles")
+		}
+		delete(doc, "profiles")
+	}
+
+	profile, ok := overrides["profile"]
+	if !ok {
+		profile, ok = lookup(envName("profile"))
+	}
+	if !ok {
+		if raw, set := doc["profile"]; set && raw != nil {
+			if profile, ok = raw.(string); !ok {
+				return fmt.Errorf("profile: must be a string, got %v", raw)
+			}
+		}
+	}
+	if profile == "" {
+		return nil
+	}
+
+	section, ok := profiles[profile].(map[string]any)
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("profile: unknown profile %q, the file defines %q", profile, names)
+	}
+	for _, field := range []string{"profile", "profiles"} {
+		if _, ok := section[field]; ok {
+			return fmt.Errorf("profiles.%s: must not set %s", profile, field)
+		}
+	}
+	merge(doc, section)
+	return nil
+}
+
+// merge copies src over dst, merging the tables present in both.
+func merge(dst, src map[string]any) {
+	for key, value := range src {
+		if table, ok := value.(map[string]any); ok {
+			if existing, ok := dst[key].(map[string]any); ok {
+				merge(existing, table)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,348 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML decodes the subset of TOML used by config files: [tables], [[arrays of
+// tables]], dotted keys, strings, integers, floats, booleans, arrays and inline tables.
+// Dates and multi-line strings are rejected.
+func parseTOML(data []byte) (map[string]any, error) {
+	root := make(map[string]any)
+	table := root
+	lines := strings.Split(string(data), "\n")
+	for n := 0; n < len(lines); n++ {
+		num := n + 1
+		line := strings.TrimSpace(stripTOMLComment(lines[n]))
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, fmt.Errorf("line %d: malformed table header %q", num, line)
+			}
+			path, err := parseTOMLKey(line[2 : len(line)-2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+			parent, err := tomlTable(root, path[:len(path)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+			last := path[len(path)-1]
+			var list []any
+			if existing, ok := parent[last]; ok {
+				if list, ok = existing.([]any); !ok {
+					return nil, fmt.Errorf("line %d: %s is not an array of tables", num, strings.Join(path, "."))
+				}
+			}
+			table = make(map[string]any)
+			parent[last] = append(list, table)
+
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed table header %q", num, line)
+			}
+			path, err := parseTOMLKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+			if table, err = tomlTable(root, path); err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+
+		default:
+			eq := tomlKeyEnd(line)
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected key = value, got %q", num, line)
+			}
+			path, err := parseTOMLKey(line[:eq])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+
+			// Arrays and inline tables may span several lines.
+			text := strings.TrimSpace(line[eq+1:])
+			for !tomlBalanced(text) && n+1 < len(lines) {
+				n++
+				text += " " + strings.TrimSpace(stripTOMLComment(lines[n]))
+			}
+			p := &tomlValue{text: text}
+			value, err := p.value()
+			if err == nil {
+				p.skipSpaces()
+				if p.pos != len(p.text) {
+					err = fmt.Errorf("unexpected %q after the value", p.text[p.pos:])
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", num, strings.Join(path, "."), err)
+			}
+
+			if err := tomlSet(table, path, value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+		}
+	}
+	return root, nil
+}
+
+// tomlTable returns the table at path below root, creating the missing ones. A path
+// through an array of tables continues in its last table.
+func tomlTable(root map[string]any, path []string) (map[string]any, error) {
+	table := root
+	for i, key := range path {
+		switch next := table[key].(type) {
+		case nil:
+			created := make(map[string]any)
+			table[key] = created
+			table = created
+		case map[string]any:
+			table = next
+		case []any:
+			last, ok := next[len(next)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(path[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+func tomlSet(table map[string]any, path []string, value any) error {
+	parent, err := tomlTable(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	if _, dup := parent[last]; dup {
+		return fmt.Errorf("duplicate key %s", strings.Join(path, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseTOMLKey splits a dotted key, whose parts are bare or quoted.
+func parseTOMLKey(text string) ([]string, error) {
+	var path []string
+	for rest := strings.TrimSpace(text); ; {
+		if rest == "" {
+			return nil, fmt.Errorf("malformed key %q", text)
+		}
+
+		var part string
+		if rest[0] == '"' || rest[0] == '\'' {
+			end := strings.IndexByte(rest[1:], rest[0]) + 1
+			if end == 0 {
+				return nil, fmt.Errorf("malformed key %q", text)
+			}
+			part, rest = rest[1:end], strings.TrimSpace(rest[end+1:])
+		} else {
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				end = len(rest)
+			}
+			part, rest = strings.TrimSpace(rest[:end]), rest[end:]
+			if part == "" || strings.ContainsAny(part, " \t\"'") {
+				return nil, fmt.Errorf("malformed key %q", text)
+			}
+		}
+		path = append(path, part)
+
+		if rest == "" {
+			return path, nil
+		}
+		if rest[0] != '.' {
+			return nil, fmt.Errorf("malformed key %q", text)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+}
+
+// tomlKeyEnd returns the index of the = ending the key of line, or -1.
+func tomlKeyEnd(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+// stripTOMLComment drops a # comment outside strings.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tomlBalanced reports whether every bracket and brace opened in text is closed.
+func tomlBalanced(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+type tomlValue struct {
+	text string
+	pos  int
+}
+
+func (p *tomlValue) skipSpaces() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlValue) value() (any, error) {
+	p.skipSpaces()
+	if p.pos == len(p.text) {
+		return nil, fmt.Errorf("missing value")
+	}
+	rest := p.text[p.pos:]
+
+	switch rest[0] {
+	case '"', '\'':
+		if strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''") {
+			return nil, fmt.Errorf("multi-line strings are not supported")
+		}
+		end := closingQuote(rest)
+		if end < 0 || (rest[0] == '\'' && strings.IndexByte(rest[1:], '\'')+1 != end) {
+			return nil, fmt.Errorf("malformed string %s", rest)
+		}
+		p.pos += end + 1
+		if rest[0] == '\'' {
+			return rest[1:end], nil
+		}
+		return strconv.Unquote(rest[:end+1])
+
+	case '[':
+		p.pos++
+		list := []any{}
+		err := p.items(']', func() error {
+			item, err := p.value()
+			list = append(list, item)
+			return err
+		})
+		return list, err
+
+	case '{':
+		p.pos++
+		table := make(map[string]any)
+		err := p.items('}', func() error {
+			eq := tomlKeyEnd(p.text[p.pos:])
+			if eq < 0 {
+				return fmt.Errorf("expected key = value in %q", p.text)
+			}
+			path, err := parseTOMLKey(p.text[p.pos : p.pos+eq])
+			if err != nil {
+				return err
+			}
+			p.pos += eq + 1
+			value, err := p.value()
+			if err != nil {
+				return err
+			}
+			return tomlSet(table, path, value)
+		})
+		return table, err
+	}
+
+	end := strings.IndexAny(rest, ",]} \t")
+	if end < 0 {
+		end = len(rest)
+	}
+	p.pos += end
+	return tomlScalar(rest[:end])
+}
+
+// items parses comma separated items up to end, the opening bracket already consumed.
+func (p *tomlValue) items(end byte, item func() error) error {
+	for {
+		p.skipSpaces()
+		if p.pos < len(p.text) && p.text[p.pos] == end {
+			p.pos++
+			return nil
+		}
+		if err := item(); err != nil {
+			return err
+		}
+		p.skipSpaces()
+		if p.pos == len(p.text) {
+			return fmt.Errorf("missing %c", end)
+		}
+		switch p.text[p.pos] {
+		case ',':
+			p.pos++
+		case end:
+		default:
+			return fmt.Errorf("unexpected %q", p.text[p.pos:])
+		}
+	}
+}
+
+func tomlScalar(text string) (any, error) {
+	switch text {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return nil, fmt.Errorf("%s is not supported", text)
+	}
+
+	number := strings.ReplaceAll(text, "_", "")
+	if n, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", text)
+}
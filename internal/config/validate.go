@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"own-database-cache/internal/logging"
+)
+
+// Validate reports every invalid field of c, named as in the config file.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, field, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	for _, path := range c.PathConfig.dataDirs() {
+		check(path.dir != "", path.field, "must be set")
+		check(path.dir == "" || strings.HasSuffix(path.dir, "/"), path.field, "directory %q must end with /", path.dir)
+	}
+	check(strings.HasSuffix(c.PathConfig.FileName, ".csv") && !strings.Contains(c.PathConfig.FileName, "/"),
+		"path.fileName", "must be a .csv file name, got %q", c.PathConfig.FileName)
+
+	check(c.ExpirationTimeCache > 0, "expirationTimeCache", "must be a positive number of seconds, got %d", c.ExpirationTimeCache)
+	check(c.ExpirationJitter >= 0 && c.ExpirationJitter < 1, "expirationJitter", "must be in [0, 1), got %v", c.ExpirationJitter)
+	for i, policy := range c.TTLPolicies {
+		field := fmt.Sprintf("ttlPolicies[%d]", i)
+		check(policy.Prefix != "", field+".prefix", "must be set")
+		check(policy.TTL > 0, field+".ttl", "must be a positive number of seconds, got %d", policy.TTL)
+		check(policy.Jitter >= 0 && policy.Jitter < 1, field+".jitter", "must be in [0, 1), got %v", policy.Jitter)
+	}
+	check(c.StaleGraceCache >= 0, "staleGraceCache", "must not be negative, got %d", c.StaleGraceCache)
+
+	check(c.L1Cache.MaxEntries >= 0, "l1Cache.maxEntries", "must not be negative, got %d", c.L1Cache.MaxEntries)
+	check(c.L1Cache.TTLMillis >= 0, "l1Cache.ttlMillis", "must not be negative, got %d", c.L1Cache.TTLMillis)
+
+	check(c.CacheLimits.MaxEntries >= 0, "cacheLimits.maxEntries", "must not be negative, got %d", c.CacheLimits.MaxEntries)
+	check(c.CacheLimits.MaxBytes >= 0, "cacheLimits.maxBytes", "must not be negative, got %d", c.CacheLimits.MaxBytes)
+	_, err := logging.ParseLevel(c.LogLevel)
+	check(err == nil, "logLevel", "%v", err)
+	err = logging.CheckFormat(c.LogFormat)
+	check(err == nil, "logFormat", "%v", err)
+	check(c.SlowQueryMillis >= 0, "slowQueryMillis", "must not be negative, got %d", c.SlowQueryMillis)
+
+	if c.Warmup.Enabled {
+		check(c.Warmup.Table != "", "warmup.table", "must be set when warm-up is enabled")
+		check(c.Warmup.KeyColumn != "", "warmup.keyColumn", "must be set when warm-up is enabled")
+		check(c.Warmup.ValueColumn != "", "warmup.valueColumn", "must be set when warm-up is enabled")
+	}
+	check(c.Warmup.Concurrency > 0, "warmup.concurrency", "must be positive, got %d", c.Warmup.Concurrency)
+
+	if c.Invalidation.Enabled {
+		check(c.Invalidation.Table != "", "invalidation.table", "must be set when invalidation is enabled")
+		check(c.Invalidation.KeyColumn != "", "invalidation.keyColumn", "must be set when invalidation is enabled")
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckPaths reports the data directories that do not exist.
+func (c *Config) CheckPaths() error {
+	var errs []error
+	for _, path := range c.PathConfig.dataDirs() {
+		info, err := os.Stat(path.dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path.field, err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s: %s is not a directory", path.field, path.dir))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type dirField struct {
+	field, dir string
+}
+
+func (p PathConfig) dataDirs() []dirField {
+	return []dirField{
+		{"path.databaseFilePath", p.DatabaseFilePath},
+		{"path.cacheFilePath", p.CacheFilePath},
+	}
+}
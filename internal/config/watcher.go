@@ -0,0 +1,171 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadable lists the fields applied without a restart, a name ending with "."
+// covers a whole section.
+var reloadable = []string{
+	"expirationTimeCache",
+	"expirationJitter",
+	"ttlPolicies",
+	"cacheLimits.",
+	"logLevel",
+	"slowQueryMillis",
+}
+
+// Reloadable reports whether the field called name can change without a restart.
+func Reloadable(name string) bool {
+	for _, field := range reloadable {
+		if name == field || strings.HasSuffix(field, ".") && strings.HasPrefix(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldChange is a field whose value differs between two configs, formatted as in Fields.
+type FieldChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// Diff lists the fields whose value differs between old and new.
+func Diff(old, new *Config) []FieldChange {
+	oldValues := make(map[string]string)
+	walk(reflect.ValueOf(old).Elem(), "", func(name string, field reflect.Value) error {
+		oldValues[name] = format(field)
+		return nil
+	})
+
+	var changes []FieldChange
+	walk(reflect.ValueOf(new).Elem(), "", func(name string, field reflect.Value) error {
+		if value := format(field); value != oldValues[name] {
+			changes = append(changes, FieldChange{Name: name, Old: oldValues[name], New: value})
+		}
+		return nil
+	})
+	return changes
+}
+
+type Reload struct {
+	// Config is the running config with the Applied changes.
+	Config  *Config
+	Applied []FieldChange
+	// RestartRequired lists the changed fields that are only read on start, Config
+	// keeps their running values.
+	RestartRequired []FieldChange
+}
+
+type WatcherConfig struct {
+	Path string
+	// Overrides are applied over every reloaded file, as by Load.
+	Overrides map[string]string
+	// Interval is how often the file is checked for changes, 1s by default.
+	Interval time.Duration
+	// OnReload applies a changed config, returning an error rejects it and keeps the
+	// running config.
+	OnReload func(Reload) error
+	// OnError reports config files that could not be loaded or were rejected.
+	OnError func(error)
+}
+
+// Watcher polls the config file and hands the changes of the reloadable fields to OnReload.
+type Watcher struct {
+	cfg WatcherConfig
+
+	mu      sync.Mutex
+	current *Config
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher returns a watcher of the file current was loaded from.
+func NewWatcher(current *Config, cfg WatcherConfig) *Watcher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+
+	w := &Watcher{cfg: cfg, current: current}
+	if info, err := os.Stat(cfg.Path); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+	return w
+}
+
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.current
+}
+
+// Run checks the file every Interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Check(); err != nil && w.cfg.OnError != nil {
+				w.cfg.OnError(err)
+			}
+		}
+	}
+}
+
+// Check reloads the file if it was modified since the last check.
+func (w *Watcher) Check() error {
+	info, err := os.Stat(w.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("config reload: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return nil
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+
+	next, err := Load(w.cfg.Path, w.cfg.Overrides)
+	if err != nil {
+		return fmt.Errorf("config reload rejected: %w", err)
+	}
+
+	config := *w.current
+	reload := Reload{Config: &config}
+	for _, change := range Diff(w.current, next) {
+		if !Reloadable(change.Name) {
+			reload.RestartRequired = append(reload.RestartRequired, change)
+			continue
+		}
+		if err := Set(reload.Config, change.Name, change.New); err != nil {
+			return fmt.Errorf("config reload rejected: %w", err)
+		}
+		reload.Applied = append(reload.Applied, change)
+	}
+	if len(reload.Applied) == 0 && len(reload.RestartRequired) == 0 {
+		return nil
+	}
+
+	if w.cfg.OnReload != nil {
+		if err := w.cfg.OnReload(reload); err != nil {
+			return fmt.Errorf("config reload rejected: %w", err)
+		}
+	}
+	w.current = reload.Config
+	return nil
+}
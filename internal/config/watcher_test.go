@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcherReloads(t *testing.T) {
+	path := writeConfig(t, `{"expirationTimeCache": 5, "logLevel": "info"}`)
+	current, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	var reloads []Reload
+	reject := false
+	watcher := NewWatcher(current, WatcherConfig{
+		Path: path,
+		OnReload: func(reload Reload) error {
+			if reject {
+				return errors.New("rejected")
+			}
+			reloads = append(reloads, reload)
+			return nil
+		},
+	})
+
+	if err := watcher.Check(); err != nil || len(reloads) != 0 {
+		t.Fatalf("Check() = %v with %d reloads, want nothing for an unchanged file", err, len(reloads))
+	}
+
+	rewrite := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		modTime := time.Now().Add(time.Duration(len(reloads)+1) * time.Second)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	rewrite(`{"expirationTimeCache": 10, "logLevel": "debug", "path": {"fileName": "other.csv"}}`)
+	if err := watcher.Check(); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(reloads) != 1 {
+		t.Fatalf("Check() made %d reloads, want 1", len(reloads))
+	}
+	reload := reloads[0]
+	if len(reload.Applied) != 2 || len(reload.RestartRequired) != 1 || reload.RestartRequired[0].Name != "path.fileName" {
+		t.Fatalf("Check() = %+v", reload)
+	}
+	if got := watcher.Current(); got.ExpirationTimeCache != 10 || got.LogLevel != "debug" || got.PathConfig.FileName != "file.csv" {
+		t.Fatalf("Current() = %+v, want only the reloadable fields changed", got)
+	}
+
+	rewrite(`{"expirationTimeCache": -1}`)
+	if err := watcher.Check(); err == nil || !strings.Contains(err.Error(), "expirationTimeCache") {
+		t.Fatalf("Check() error = %v, want the invalid field", err)
+	}
+
+	reject = true
+	rewrite(`{"expirationTimeCache": 20}`)
+	if err := watcher.Check(); err == nil {
+		t.Fatal("Check() should report a rejected reload")
+	}
+	if got := watcher.Current(); got.ExpirationTimeCache != 10 {
+		t.Fatalf("ExpirationTimeCache = %d, a rejected reload should not apply", got.ExpirationTimeCache)
+	}
+}
+
+func TestReloadable(t *testing.T) {
+	for name, want := range map[string]bool{
+		"expirationTimeCache":   true,
+		"cacheLimits.maxBytes":  true,
+		"path.databaseFilePath": false,
+		"l1Cache.enabled":       false,
+	} {
+		if got := Reloadable(name); got != want {
+			t.Fatalf("Reloadable(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
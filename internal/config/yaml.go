@@ -0,0 +1,364 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML decodes the subset of YAML used by config files: nested block mappings and
+// sequences, plain and quoted scalars, flow [lists] and {maps}, and comments. Anchors,
+// tags, block scalars and multiple documents are rejected.
+func parseYAML(data []byte) (map[string]any, error) {
+	p := &yamlParser{}
+	for n, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripComment(strings.TrimRight(raw, "\r")), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (n == 0 && trimmed == "---") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", n+1)
+		}
+		if trimmed == "---" || trimmed == "..." {
+			return nil, fmt.Errorf("line %d: multiple documents are not supported", n+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: n + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+
+	if len(p.lines) == 0 {
+		return map[string]any{}, nil
+	}
+	if p.lines[0].indent != 0 || isSequenceItem(p.lines[0].text) {
+		return nil, fmt.Errorf("line %d: the document must be a mapping", p.lines[0].num)
+	}
+	return p.mapping(0)
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(line yamlLine, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", line.num, fmt.Sprintf(format, args...))
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	m := make(map[string]any)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+		if isSequenceItem(line.text) {
+			return nil, p.errorf(line, "unexpected list item in a mapping")
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, p.errorf(line, "expected key: value, got %q", line.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf(line, "duplicate key %q", key)
+		}
+		p.pos++
+
+		value, err := p.value(line, indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// value parses what follows "key:" or "- ": an inline value, or the block on the next lines.
+func (p *yamlParser) value(line yamlLine, indent int, rest string) (any, error) {
+	if rest != "" {
+		value, err := parseYAMLScalar(rest)
+		if err != nil {
+			return nil, p.errorf(line, "%v", err)
+		}
+		return value, nil
+	}
+
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	switch {
+	case next.indent > indent && isSequenceItem(next.text):
+		return p.sequence(next.indent)
+	case next.indent > indent:
+		return p.mapping(next.indent)
+	case next.indent == indent && isSequenceItem(next.text) && !isSequenceItem(line.text):
+		// A list may sit at the indentation of its key.
+		return p.sequence(indent)
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && !isSequenceItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if _, _, ok := splitYAMLKey(rest); ok && rest[0] != '[' && rest[0] != '{' {
+			// "- key: value" starts a mapping indented past the dash.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+			item, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		p.pos++
+		item, err := p.value(line, indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: value" into its key and the trimmed value.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text == "" {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		key, err := unquoteYAML(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		rest = text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest), true
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key = strings.TrimSpace(text[:i])
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// closingQuote returns the index of the quote closing the string text starts with, or -1.
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(text string) (string, error) {
+	if text[0] == '\'' {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	return strconv.Unquote(text)
+}
+
+// stripComment drops a # comment starting the line or following a space, outside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseYAMLScalar(text string) (any, error) {
+	switch text[0] {
+	case '[', '{':
+		f := &yamlFlow{text: text}
+		value, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpaces()
+		if f.pos != len(f.text) {
+			return nil, fmt.Errorf("unexpected %q after %q", f.text[f.pos:], f.text[:f.pos])
+		}
+		return value, nil
+	case '"', '\'':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("malformed string %s", text)
+		}
+		return unquoteYAML(text)
+	case '|', '>':
+		return nil, fmt.Errorf("block scalars are not supported")
+	case '&', '*', '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	}
+	return plainScalar(text), nil
+}
+
+// plainScalar resolves an unquoted scalar to a bool, null, number or string.
+func plainScalar(text string) any {
+	switch text {
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case "null", "Null", "NULL", "~":
+		return nil
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	return text
+}
+
+// yamlFlow parses the flow collections [a, b] and {a: 1, b: 2}.
+type yamlFlow struct {
+	text string
+	pos  int
+}
+
+func (f *yamlFlow) skipSpaces() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value() (any, error) {
+	f.skipSpaces()
+	if f.pos == len(f.text) {
+		return nil, fmt.Errorf("unexpected end of %q", f.text)
+	}
+
+	switch c := f.text[f.pos]; c {
+	case '[':
+		f.pos++
+		list := []any{}
+		err := f.items(']', func() error {
+			item, err := f.value()
+			list = append(list, item)
+			return err
+		})
+		return list, err
+	case '{':
+		f.pos++
+		m := make(map[string]any)
+		err := f.items('}', func() error {
+			key, err := f.scalar(true)
+			if err != nil {
+				return err
+			}
+			f.skipSpaces()
+			if f.pos == len(f.text) || f.text[f.pos] != ':' {
+				return fmt.Errorf("expected : after key %v in %q", key, f.text)
+			}
+			f.pos++
+			value, err := f.value()
+			m[fmt.Sprint(key)] = value
+			return err
+		})
+		return m, err
+	}
+	return f.scalar(false)
+}
+
+// items parses comma separated items up to end, the opening bracket already consumed.
+func (f *yamlFlow) items(end byte, item func() error) error {
+	for {
+		f.skipSpaces()
+		if f.pos < len(f.text) && f.text[f.pos] == end {
+			f.pos++
+			return nil
+		}
+		if err := item(); err != nil {
+			return err
+		}
+		f.skipSpaces()
+		if f.pos == len(f.text) {
+			return fmt.Errorf("missing %c in %q", end, f.text)
+		}
+		switch f.text[f.pos] {
+		case ',':
+			f.pos++
+		case end:
+		default:
+			return fmt.Errorf("unexpected %q in %q", f.text[f.pos], f.text)
+		}
+	}
+}
+
+func (f *yamlFlow) scalar(key bool) (any, error) {
+	f.skipSpaces()
+	rest := f.text[f.pos:]
+	if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+		end := closingQuote(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("malformed string %s", rest)
+		}
+		f.pos += end + 1
+		return unquoteYAML(rest[:end+1])
+	}
+
+	stop := ",]}"
+	if key {
+		stop += ":"
+	}
+	end := strings.IndexAny(rest, stop)
+	if end < 0 {
+		end = len(rest)
+	}
+	f.pos += end
+	return plainScalar(strings.TrimSpace(rest[:end])), nil
+}
@@ -5,14 +5,43 @@ import (
 	"time"
 
 	"own-database-cache/internal/datasource"
+	"own-database-cache/internal/tenant"
 )
 
 type Client struct {
 	source datasource.Datasource
 }
 
-func NewClient(source datasource.Datasource) *Client {
-	return &Client{source: source}
+// NewClient returns a client over source wrapped with middlewares, the first one is the outermost.
+func NewClient(source datasource.Datasource, middlewares ...datasource.Middleware) *Client {
+	return &Client{source: datasource.Chain(source, middlewares...)}
+}
+
+// NewLayeredClient returns a client reading through cache into database, with writes
+// following cfg.Mode. Call Close to flush pending write-behind writes.
+func NewLayeredClient(cache, database datasource.Datasource, cfg datasource.LayeredConfig, middlewares ...datasource.Middleware) *Client {
+	return NewClient(datasource.NewLayered(cache, database, cfg), middlewares...)
+}
+
+// NewHedgedClient returns a client reading from several replicas at once, or hedging
+// after cfg.HedgeDelay, and returning the first answer. Writes go to every replica.
+func NewHedgedClient(sources []datasource.Datasource, cfg datasource.HedgedConfig, middlewares ...datasource.Middleware) *Client {
+	return NewClient(datasource.NewHedged(sources, cfg), middlewares...)
+}
+
+// NewTenantClient returns a client serving tenant id only: its keys, quotas and database.
+func NewTenantClient(tenants *tenant.Registry, id string, middlewares ...datasource.Middleware) (*Client, error) {
+	source, err := tenants.Source(id)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(source, middlewares...), nil
+}
+
+// NewMultiTenantClient returns a client serving the tenant set with tenant.WithTenant
+// on the context of each call, calls without one fail with tenant.ErrNoTenant.
+func NewMultiTenantClient(tenants *tenant.Registry, middlewares ...datasource.Middleware) *Client {
+	return NewClient(tenants.Router(), middlewares...)
 }
 
 func (c *Client) Set(
@@ -24,6 +53,45 @@ func (c *Client) Set(
 	return c.source.Set(ctx, key, value, expiration)
 }
 
+// Get returns datasource.ErrNotFound when the key is missing.
 func (c *Client) Get(ctx context.Context, key string) (any, error) {
 	return c.source.Get(ctx, key)
 }
+
+// GetInto decodes the value of key into dst, a non-nil pointer. It returns a
+// *datasource.TypeMismatchError when the stored value does not fit dst.
+func (c *Client) GetInto(ctx context.Context, key string, dst any) error {
+	return datasource.GetInto(ctx, c.source, key, dst)
+}
+
+// Get returns the value of key decoded as T.
+func Get[T any](ctx context.Context, c *Client, key string) (T, error) {
+	var value T
+	if err := c.GetInto(ctx, key, &value); err != nil {
+		var zero T
+		return zero, err
+	}
+	return value, nil
+}
+
+func Set[T any](ctx context.Context, c *Client, key string, value T, expiration time.Duration) error {
+	return c.Set(ctx, key, value, expiration)
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.source.Delete(ctx, key)
+}
+
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	return c.source.Exists(ctx, key)
+}
+
+// Close releases the resources of the underlying datasource, if it holds any.
+func (c *Client) Close(ctx context.Context) error {
+	if closer, ok := c.source.(interface {
+		Close(ctx context.Context) error
+	}); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"own-database-cache/internal/datasource"
+	"own-database-cache/internal/datasource/mocks"
+)
+
+func TestClientForwardsToSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	source := mocks.NewMockDatasource(ctrl)
+	client := NewClient(source)
+	ctx := context.Background()
+
+	source.EXPECT().Set(ctx, "key", "value", time.Minute).Return(nil)
+	source.EXPECT().Get(ctx, "key").Return("value", nil)
+	source.EXPECT().Exists(ctx, "key").Return(true, nil)
+	source.EXPECT().Delete(ctx, "key").Return(nil)
+	source.EXPECT().Get(ctx, "key").Return(nil, datasource.ErrNotFound)
+
+	if err := client.Set(ctx, "key", "value", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, err := client.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+	if found, err := client.Exists(ctx, "key"); err != nil || !found {
+		t.Fatalf("Exists() = %v, %v", found, err)
+	}
+	if err := client.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := client.Get(ctx, "key"); !errors.Is(err, datasource.ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, datasource.ErrNotFound)
+	}
+	if err := client.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v for a source without Close", err)
+	}
+}
+
+func TestGetTyped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	source := mocks.NewMockDatasource(ctrl)
+	client := NewClient(source)
+	ctx := context.Background()
+
+	source.EXPECT().Set(ctx, "count", 42, time.Duration(0)).Return(nil)
+	source.EXPECT().Get(ctx, "count").Return(42, nil).Times(2)
+
+	if err := Set(ctx, client, "count", 42, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, err := Get[int](ctx, client, "count"); err != nil || got != 42 {
+		t.Fatalf("Get[int]() = %v, %v", got, err)
+	}
+
+	got, err := Get[string](ctx, client, "count")
+	var mismatch *datasource.TypeMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Key != "count" || mismatch.Want != "string" {
+		t.Fatalf("Get[string]() error = %v, want a type mismatch", err)
+	}
+	if got != "" {
+		t.Fatalf("Get[string]() = %q, want the zero value on error", got)
+	}
+}
+
+// decodingSource is a datasource able to decode values into a destination.
+type decodingSource struct {
+	*mocks.MockDatasource
+	*mocks.MockDecoder
+}
+
+func TestGetIntoUsesDecoder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	source := decodingSource{mocks.NewMockDatasource(ctrl), mocks.NewMockDecoder(ctrl)}
+	client := NewClient(source)
+	ctx := context.Background()
+
+	type profile struct{ Name string }
+	source.MockDecoder.EXPECT().GetInto(ctx, "user:1", gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key string, dst any) error {
+			*dst.(*profile) = profile{Name: "Alice"}
+			return nil
+		})
+
+	got, err := Get[profile](ctx, client, "user:1")
+	if err != nil || got.Name != "Alice" {
+		t.Fatalf("Get[profile]() = %+v, %v", got, err)
+	}
+}
+
+func TestClientAppliesMiddlewares(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	source := mocks.NewMockDatasource(ctrl)
+	metrics := datasource.NewMetrics()
+	client := NewClient(source,
+		datasource.WithMetrics(metrics),
+		datasource.WithRetry(datasource.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}),
+	)
+	ctx := context.Background()
+
+	gomock.InOrder(
+		source.EXPECT().Get(gomock.Any(), "key").Return(nil, errors.New("disk is busy")),
+		source.EXPECT().Get(gomock.Any(), "key").Return("value", nil),
+	)
+
+	if got, err := client.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want the retried value", got, err)
+	}
+	if stats := metrics.Snapshot()[datasource.OpGet]; stats.Calls != 1 || stats.Errors != 0 {
+		t.Fatalf("metrics = %+v, want one successful call", stats)
+	}
+}
+
+func TestLayeredClientReadsThrough(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cache := mocks.NewMockDatasource(ctrl)
+	database := mocks.NewMockDatasource(ctrl)
+	client := NewLayeredClient(cache, database, datasource.LayeredConfig{CacheTTL: time.Minute})
+	ctx := context.Background()
+
+	gomock.InOrder(
+		cache.EXPECT().Get(ctx, "key").Return(nil, datasource.ErrNotFound),
+		database.EXPECT().Get(ctx, "key").Return("value", nil),
+		cache.EXPECT().Set(ctx, "key", "value", time.Minute).Return(nil),
+		cache.EXPECT().Get(ctx, "key").Return("value", nil),
+	)
+
+	for i := 0; i < 2; i++ {
+		if got, err := client.Get(ctx, "key"); err != nil || got != "value" {
+			t.Fatalf("Get() = %v, %v", got, err)
+		}
+	}
+}
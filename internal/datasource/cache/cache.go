@@ -2,45 +2,248 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"log/slog"
 	"time"
 
+	"own-database-cache/internal/config"
+	"own-database-cache/internal/datasource"
 	pkg "own-database-cache/pkg/cache"
+	db "own-database-cache/pkg/database"
 )
 
 type Client struct {
-	cache *pkg.Cache
+	cache      *pkg.Cache
+	ttl        *TTLPolicy
+	codec      datasource.Codec
+	l1         *l1Cache
+	staleGrace time.Duration
+	logger     *slog.Logger
 }
 
-func NewClient(file string) *Client {
-	return &Client{
-		cache: pkg.NewCache(file),
+type Option func(*Client)
+
+// WithTTLPolicy makes Set use the policy when it is called with zero expiration.
+func WithTTLPolicy(policy *TTLPolicy) Option {
+	return func(c *Client) {
+		c.ttl = policy
+	}
+}
+
+// WithCodec sets the encoding of stored values, JSON by default.
+func WithCodec(codec datasource.Codec) Option {
+	return func(c *Client) {
+		c.codec = codec
+	}
+}
+
+// WithStaleGrace keeps expired values for grace so GetStale can serve them.
+func WithStaleGrace(grace time.Duration) Option {
+	return func(c *Client) {
+		c.staleGrace = grace
+	}
+}
+
+// WithL1 puts a small in-memory cache in front of the persistent one. Values read from
+// the persistent cache are kept in memory for cfg.TTL and dropped as soon as the
+// persistent cache changes them. Reads served from memory are not tracked.
+func WithL1(cfg L1Config) Option {
+	return func(c *Client) {
+		c.l1 = newL1Cache(cfg)
+	}
+}
+
+// WithLogger sets the logger of the cache, slog.Default() by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+func NewClient(file string, opts ...Option) *Client {
+	c := &Client{
+		codec:  datasource.JSONCodec{},
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.cache = pkg.NewCache(file, pkg.WithLogger(c.logger))
+	c.cache.KeepStale(c.staleGrace)
+	if c.l1 != nil {
+		c.cache.Subscribe(c.l1.invalidate)
+	}
+	return c
+}
+
+// NewClientFromConfig returns a client over the cache file of cfg with its TTL
+// policies, stale grace period and L1 cache; opts are applied after them.
+func NewClientFromConfig(cfg *config.Config, opts ...Option) (*Client, error) {
+	ttlPolicy, err := NewTTLPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []Option{
+		WithTTLPolicy(ttlPolicy),
+		WithStaleGrace(time.Duration(cfg.StaleGraceCache) * time.Second),
+	}
+	if cfg.L1Cache.Enabled {
+		options = append(options, WithL1(L1Config{
+			MaxEntries: cfg.L1Cache.MaxEntries,
+			TTL:        time.Duration(cfg.L1Cache.TTLMillis) * time.Millisecond,
+		}))
 	}
+
+	c := NewClient(cfg.PathConfig.CacheFilePath+cfg.PathConfig.FileName, append(options, opts...)...)
+	c.cache.SetQuota(limits(cfg))
+	return c, nil
+}
+
+// Reload applies the default TTLs and the size limits of cfg. Nothing is changed when
+// cfg is invalid.
+func (c *Client) Reload(cfg *config.Config) error {
+	if c.ttl != nil {
+		if err := c.ttl.Reload(cfg); err != nil {
+			return err
+		}
+	}
+	c.cache.SetQuota(limits(cfg))
+	return nil
+}
+
+// limits returns the quota over all keys. Keys with a quota of their own must stay within
+// both, and keys under pkg.InternalPrefix are left out.
+func limits(cfg *config.Config) pkg.Quota {
+	return pkg.Quota{MaxEntries: cfg.CacheLimits.MaxEntries, MaxBytes: cfg.CacheLimits.MaxBytes}
+}
+
+// MaxExpiration returns the longest expiration Set may pick for key when it is called
+// without one, following reloads of the TTL policy. It is zero without a policy.
+func (c *Client) MaxExpiration(key string) time.Duration {
+	if c.ttl == nil {
+		return 0
+	}
+	return c.ttl.MaxExpiration(key)
+}
+
+func (c *Client) Cache() *pkg.Cache {
+	return c.cache
 }
 
 func (c *Client) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
-	serializedValue, err := json.Marshal(value)
+	if expiration == 0 && c.ttl != nil {
+		expiration = c.ttl.Expiration(key)
+	}
+
+	serializedValue, err := c.codec.Encode(value)
 	if err != nil {
 		return err
 	}
 
-	return c.cache.Set(ctx, key, string(serializedValue), expiration)
+	return c.cache.Set(ctx, key, serializedValue, expiration)
 }
 
 func (c *Client) Get(ctx context.Context, key string) (any, error) {
-	serializedValue, found, err := c.cache.Get(ctx, key)
-	if err != nil {
+	var value any
+	if err := c.GetInto(ctx, key, &value); err != nil {
 		return nil, err
 	}
-	if !found {
-		return nil, nil
+	return value, nil
+}
+
+// GetInto decodes the value of key into dst, a non-nil pointer.
+func (c *Client) GetInto(ctx context.Context, key string, dst any) error {
+	serializedValue, found, err := c.get(ctx, key)
+	return c.decode(key, serializedValue, found, err, dst)
+}
+
+func (c *Client) get(ctx context.Context, key string) (string, bool, error) {
+	if c.l1 == nil {
+		return c.cache.Get(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", false, err
 	}
 
+	if serializedValue, found := c.l1.get(key); found {
+		return serializedValue, true, nil
+	}
+
+	generation := c.l1.generation.Load()
+	serializedValue, found, err := c.cache.Get(ctx, key)
+	if err == nil && found {
+		c.l1.promote(key, serializedValue, generation)
+	}
+	return serializedValue, found, err
+}
+
+// GetStale also returns values that expired within the WithStaleGrace period.
+func (c *Client) GetStale(ctx context.Context, key string) (any, error) {
+	serializedValue, found, err := c.cache.GetStale(ctx, key)
+
 	var value any
-	err = json.Unmarshal([]byte(serializedValue), &value)
-	if err != nil {
+	if err := c.decode(key, serializedValue, found, err, &value); err != nil {
 		return nil, err
 	}
-
 	return value, nil
 }
+
+func (c *Client) decode(key, serializedValue string, found bool, err error, dst any) error {
+	if err != nil {
+		return err
+	}
+	if !found {
+		return datasource.ErrNotFound
+	}
+
+	return datasource.DecodeInto(c.codec, key, serializedValue, dst)
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.cache.Delete(ctx, key)
+	return err
+}
+
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	_, found, err := c.get(ctx, key)
+	return found, err
+}
+
+func (c *Client) EnableTracking(cfg pkg.TrackerConfig) *pkg.Tracker {
+	return c.cache.EnableTracking(cfg)
+}
+
+// NewWarmer returns a warmer copying values from tables written by a database client
+// with the same codec, so they are stored in the cache as they are.
+func (c *Client) NewWarmer(source *db.Database, cfg pkg.WarmerConfig) *pkg.Warmer {
+	if c.ttl != nil {
+		cfg.TTL = c.ttl.Expiration
+	}
+	if cfg.Encode == nil {
+		cfg.Encode = c.encodeStored
+	}
+	return pkg.NewWarmer(c.cache, source, cfg)
+}
+
+// NewInvalidator returns an invalidator for tables written by a database client with
+// the same codec, refreshed values are stored in the cache as they are.
+func (c *Client) NewInvalidator(cfg pkg.InvalidatorConfig) *pkg.Invalidator {
+	if c.ttl != nil {
+		cfg.TTL = c.ttl.Expiration
+	}
+	if cfg.Encode == nil {
+		cfg.Encode = c.encodeStored
+	}
+	return pkg.NewInvalidator(c.cache, cfg)
+}
+
+// encodeStored keeps a database value written with the codec as it is and encodes the
+// raw text held by tables written before the codec.
+func (c *Client) encodeStored(value string) (string, error) {
+	var decoded any
+	if c.codec.Decode(value, &decoded) == nil {
+		return value, nil
+	}
+	return c.codec.Encode(value)
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"own-database-cache/internal/config"
+	"own-database-cache/internal/datasource"
+	pkg "own-database-cache/pkg/cache"
+	db "own-database-cache/pkg/database"
+	"testing"
+	"time"
+)
+
+type profile struct {
+	Name string
+	Age  int
+}
+
+func init() {
+	gob.Register(profile{})
+}
+
+func TestClientGetInto(t *testing.T) {
+	ctx := context.Background()
+	want := profile{Name: "Alice", Age: 30}
+
+	for name, codec := range map[string]datasource.Codec{"json": datasource.JSONCodec{}, "gob": datasource.GobCodec{}} {
+		client := NewClient(t.TempDir()+"/cache.csv", WithCodec(codec))
+		if err := client.Set(ctx, "user:1", want, time.Minute); err != nil {
+			t.Fatalf("%s: Set() error = %v", name, err)
+		}
+
+		var got profile
+		if err := client.GetInto(ctx, "user:1", &got); err != nil || got != want {
+			t.Fatalf("%s: GetInto() = %+v, %v, want %+v", name, got, err, want)
+		}
+
+		var count int
+		var mismatch *datasource.TypeMismatchError
+		if err := client.GetInto(ctx, "user:1", &count); !errors.As(err, &mismatch) || mismatch.Key != "user:1" || mismatch.Want != "int" {
+			t.Fatalf("%s: GetInto() error = %v, want a type mismatch", name, err)
+		}
+
+		if err := client.GetInto(ctx, "user:2", &got); !errors.Is(err, datasource.ErrNotFound) {
+			t.Fatalf("%s: GetInto() error = %v, want %v", name, err, datasource.ErrNotFound)
+		}
+	}
+}
+
+func TestClientL1(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(t.TempDir()+"/cache.csv", WithL1(L1Config{MaxEntries: 2, TTL: time.Minute}))
+	now := time.Now()
+	client.l1.now = func() time.Time { return now }
+
+	client.Set(ctx, "a", "1", time.Minute)
+	if _, found := client.l1.get("a"); found {
+		t.Fatal("Set() should not populate the in-memory cache")
+	}
+	if got, err := client.Get(ctx, "a"); err != nil || got != "1" {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+	if value, found := client.l1.get("a"); !found || value != `"1"` {
+		t.Fatalf("l1.get() = %q, %v, want the promoted value", value, found)
+	}
+
+	// Writes made straight to the persistent cache invalidate the in-memory copy.
+	client.cache.Set(ctx, "a", `"2"`, time.Minute)
+	if got, err := client.Get(ctx, "a"); err != nil || got != "2" {
+		t.Fatalf("Get() = %v, %v, want the new value", got, err)
+	}
+	client.cache.Delete(ctx, "a")
+	if found, err := client.Exists(ctx, "a"); err != nil || found {
+		t.Fatalf("Exists() = %v, %v after Delete()", found, err)
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		client.Set(ctx, key, key, time.Minute)
+		client.Get(ctx, key)
+	}
+	if size := client.l1.size.Load(); size > 2 {
+		t.Fatalf("l1 holds %d entries, want at most 2", size)
+	}
+
+	client.Get(ctx, "c")
+	now = now.Add(2 * time.Minute)
+	if _, found := client.l1.get("c"); found {
+		t.Fatal("l1.get() returned a value past its TTL")
+	}
+}
+
+func TestL1SkipsPromotionAfterWrite(t *testing.T) {
+	l1 := newL1Cache(L1Config{})
+	generation := l1.generation.Load()
+
+	l1.invalidate("key")
+	l1.promote("key", "old", generation)
+	if _, found := l1.get("key"); found {
+		t.Fatal("a value read before a write should not be promoted")
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.PathConfig.CacheFilePath = t.TempDir() + "/"
+	cfg.L1Cache.Enabled = true
+	cfg.TTLPolicies = []config.TTLPolicy{{Prefix: "session:", TTL: 300}}
+
+	client, err := NewClientFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewClientFromConfig() error = %v", err)
+	}
+	if client.l1 == nil || client.ttl == nil {
+		t.Fatal("NewClientFromConfig() should apply the L1 and TTL settings")
+	}
+	if got := client.ttl.Expiration("session:1"); got != 300*time.Second {
+		t.Fatalf("Expiration() = %v, want the policy of the config", got)
+	}
+
+	cfg.ExpirationJitter = 2
+	if _, err := NewClientFromConfig(cfg); err == nil {
+		t.Fatal("NewClientFromConfig() should reject an invalid TTL policy")
+	}
+}
+
+func TestClientReload(t *testing.T) {
+	cfg := config.Default()
+	cfg.PathConfig.CacheFilePath = t.TempDir() + "/"
+	client, err := NewClientFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewClientFromConfig() error = %v", err)
+	}
+
+	reloaded := *cfg
+	reloaded.TTLPolicies = []config.TTLPolicy{{Prefix: "user:", TTL: 30}}
+	reloaded.CacheLimits.MaxEntries = 1
+	if err := client.Reload(&reloaded); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := client.ttl.Expiration("user:1"); got != 30*time.Second {
+		t.Fatalf("Expiration() = %v after Reload()", got)
+	}
+	if got := client.MaxExpiration("user:1"); got != 30*time.Second {
+		t.Fatalf("MaxExpiration() = %v after Reload()", got)
+	}
+
+	ctx := context.Background()
+	client.Set(ctx, "a", 1, time.Minute)
+	if err := client.Set(ctx, "b", 2, time.Minute); !errors.Is(err, pkg.ErrQuotaExceeded) {
+		t.Fatalf("Set() error = %v, want the reloaded limit", err)
+	}
+
+	reloaded.ExpirationTimeCache = 0
+	if err := client.Reload(&reloaded); err == nil {
+		t.Fatal("Reload() should reject an invalid config")
+	}
+	if got := client.ttl.Expiration("other"); got != 60*time.Second {
+		t.Fatalf("Expiration() = %v, a rejected reload should keep the policy", got)
+	}
+}
+
+func TestClientWarmsLegacyRawValues(t *testing.T) {
+	ctx := context.Background()
+	source := db.NewDatabase(t.TempDir() + "/")
+	txn, _ := source.Begin(ctx)
+	source.Exec(ctx, txn, "CREATE TABLE file (key, value) WITH TYPES (string, string)")
+	source.Exec(ctx, txn, "INSERT INTO file (key, value) VALUES (?, ?), (?, ?)", "raw", "plain text", "encoded", `"json text"`)
+	if err := source.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	client := NewClient(t.TempDir() + "/cache.csv")
+	if _, err := client.NewWarmer(source, pkg.WarmerConfig{Expiration: time.Minute}).WarmTable(ctx, "file", "key", "value"); err != nil {
+		t.Fatalf("WarmTable() error = %v", err)
+	}
+
+	for key, want := range map[string]string{"raw": "plain text", "encoded": "json text"} {
+		var got string
+		if err := client.GetInto(ctx, key, &got); err != nil || got != want {
+			t.Fatalf("GetInto(%s) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type L1Config struct {
+	// MaxEntries bounds the number of values kept in memory, 1024 by default.
+	MaxEntries int
+	// TTL is how long a promoted value is served from memory, 1s by default. A value
+	// expiring in the persistent cache can be served from memory for up to TTL longer.
+	TTL time.Duration
+}
+
+type l1Entry struct {
+	value     string
+	expiresAt int64
+}
+
+// l1Cache keeps recently read values of the persistent cache in memory. Writes to the
+// persistent cache invalidate it, reads only take locks inside sync.Map.
+type l1Cache struct {
+	cfg     L1Config
+	entries sync.Map
+	size    atomic.Int64
+	// generation is bumped on every invalidation, so a read that raced with a write
+	// does not promote the value it read before the write.
+	generation atomic.Uint64
+	now        func() time.Time
+}
+
+func newL1Cache(cfg L1Config) *l1Cache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 1024
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Second
+	}
+	return &l1Cache{cfg: cfg, now: time.Now}
+}
+
+func (l *l1Cache) get(key string) (string, bool) {
+	v, ok := l.entries.Load(key)
+	if !ok {
+		return "", false
+	}
+
+	entry := v.(l1Entry)
+	if l.now().UnixNano() > entry.expiresAt {
+		l.remove(key, entry)
+		return "", false
+	}
+	return entry.value, true
+}
+
+// promote stores a value read from the persistent cache when no write happened since
+// generation was taken.
+func (l *l1Cache) promote(key, value string, generation uint64) {
+	if l.generation.Load() != generation {
+		return
+	}
+	if l.size.Load() >= int64(l.cfg.MaxEntries) {
+		l.evict()
+	}
+
+	entry := l1Entry{value: value, expiresAt: l.now().Add(l.cfg.TTL).UnixNano()}
+	if _, loaded := l.entries.Swap(key, entry); !loaded {
+		l.size.Add(1)
+	}
+	if l.generation.Load() != generation {
+		l.remove(key, entry)
+	}
+}
+
+func (l *l1Cache) invalidate(key string) {
+	l.generation.Add(1)
+	if _, loaded := l.entries.LoadAndDelete(key); loaded {
+		l.size.Add(-1)
+	}
+}
+
+func (l *l1Cache) remove(key string, entry l1Entry) {
+	if l.entries.CompareAndDelete(key, entry) {
+		l.size.Add(-1)
+	}
+}
+
+// evict drops an arbitrary entry to make room for a new one.
+func (l *l1Cache) evict() {
+	l.entries.Range(func(key, value any) bool {
+		l.remove(key.(string), value.(l1Entry))
+		return false
+	})
+}
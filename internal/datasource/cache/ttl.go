@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"own-database-cache/internal/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ttlRule struct {
+	prefix string
+	ttl    time.Duration
+	jitter float64
+}
+
+// TTLPolicy picks the expiration for keys set without one: the rule with the longest
+// matching prefix wins, otherwise the global default applies.
+type TTLPolicy struct {
+	mu     sync.RWMutex
+	rules  []ttlRule
+	global ttlRule
+	random func() float64
+}
+
+func NewTTLPolicy(cfg *config.Config) (*TTLPolicy, error) {
+	policy := &TTLPolicy{random: rand.Float64}
+	if err := policy.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Reload replaces the rules with the ones of cfg, keeping the current rules when cfg is invalid.
+func (p *TTLPolicy) Reload(cfg *config.Config) error {
+	global := ttlRule{
+		ttl:    time.Duration(cfg.ExpirationTimeCache) * time.Second,
+		jitter: cfg.ExpirationJitter,
+	}
+	if err := global.validate(); err != nil {
+		return fmt.Errorf("invalid default expiration: %w", err)
+	}
+
+	rules := make([]ttlRule, 0, len(cfg.TTLPolicies))
+	for _, policy := range cfg.TTLPolicies {
+		rule := ttlRule{
+			prefix: strings.TrimSuffix(policy.Prefix, "*"),
+			ttl:    time.Duration(policy.TTL) * time.Second,
+			jitter: policy.Jitter,
+		}
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("invalid ttl policy %q: %w", policy.Prefix, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].prefix) > len(rules[j].prefix)
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rules = rules
+	p.global = global
+	return nil
+}
+
+func (p *TTLPolicy) Expiration(key string) time.Duration {
+	rule := p.rule(key)
+	if rule.jitter == 0 {
+		return rule.ttl
+	}
+	return time.Duration(float64(rule.ttl) * (1 + rule.jitter*(2*p.random()-1)))
+}
+
+// MaxExpiration returns the longest expiration Expiration may pick for key.
+func (p *TTLPolicy) MaxExpiration(key string) time.Duration {
+	rule := p.rule(key)
+	return time.Duration(float64(rule.ttl) * (1 + rule.jitter))
+}
+
+func (p *TTLPolicy) rule(key string) ttlRule {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, r := range p.rules {
+		if strings.HasPrefix(key, r.prefix) {
+			return r
+		}
+	}
+	return p.global
+}
+
+func (r ttlRule) validate() error {
+	if r.ttl <= 0 {
+		return fmt.Errorf("ttl must be positive, got %v", r.ttl)
+	}
+	if r.jitter < 0 || r.jitter >= 1 {
+		return fmt.Errorf("jitter must be in [0, 1), got %v", r.jitter)
+	}
+	return nil
+}
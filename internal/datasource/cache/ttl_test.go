@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"own-database-cache/internal/config"
+	"testing"
+	"time"
+)
+
+func TestTTLPolicyExpiration(t *testing.T) {
+	policy, err := NewTTLPolicy(&config.Config{
+		ExpirationTimeCache: 5,
+		TTLPolicies: []config.TTLPolicy{
+			{Prefix: "user:*", TTL: 300, Jitter: 0.1},
+			{Prefix: "user:admin:*", TTL: 60},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewTTLPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		random float64
+		want   time.Duration
+	}{
+		{"session:1", 0.5, 5 * time.Second},
+		{"user:1", 0, 270 * time.Second},
+		{"user:1", 0.5, 300 * time.Second},
+		{"user:1", 1, 330 * time.Second},
+		{"user:admin:1", 0, 60 * time.Second},
+	}
+
+	for _, tt := range tests {
+		random := tt.random
+		policy.random = func() float64 { return random }
+		if got := policy.Expiration(tt.key); got != tt.want {
+			t.Errorf("Expiration(%s) with random %v = %v, want %v", tt.key, tt.random, got, tt.want)
+		}
+	}
+}
+
+func TestTTLPolicyMaxExpiration(t *testing.T) {
+	policy, err := NewTTLPolicy(&config.Config{
+		ExpirationTimeCache: 5,
+		ExpirationJitter:    0.2,
+		TTLPolicies:         []config.TTLPolicy{{Prefix: "user:*", TTL: 300, Jitter: 0.1}},
+	})
+	if err != nil {
+		t.Fatalf("NewTTLPolicy() error = %v", err)
+	}
+
+	for key, want := range map[string]time.Duration{"session:1": 6 * time.Second, "user:1": 330 * time.Second} {
+		if got := policy.MaxExpiration(key); got != want {
+			t.Errorf("MaxExpiration(%s) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestTTLPolicyValidation(t *testing.T) {
+	tests := []*config.Config{
+		{},
+		{ExpirationTimeCache: 5, ExpirationJitter: 1},
+		{ExpirationTimeCache: 5, TTLPolicies: []config.TTLPolicy{{Prefix: "user:*"}}},
+		{ExpirationTimeCache: 5, TTLPolicies: []config.TTLPolicy{{Prefix: "user:*", TTL: 10, Jitter: -0.1}}},
+	}
+
+	for _, cfg := range tests {
+		if _, err := NewTTLPolicy(cfg); err == nil {
+			t.Errorf("NewTTLPolicy(%+v) should fail", cfg)
+		}
+	}
+}
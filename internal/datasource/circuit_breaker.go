@@ -0,0 +1,258 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	// StateClosed passes every call through and counts consecutive failures.
+	StateClosed BreakerState = iota
+	// StateOpen rejects calls with ErrCircuitOpen until OpenTimeout passes.
+	StateOpen
+	// StateHalfOpen lets a few probe calls through to decide whether to close again.
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing the datasource.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of calls let through while half-open, the circuit
+	// closes once all of them succeed and opens again on the first failure.
+	HalfOpenProbes int
+	// IsFailure decides which errors count as failures, by default everything
+	// except misses, type mismatches and canceled calls.
+	IsFailure func(err error) bool
+	// OnStateChange is called on every transition with the breaker locked, it must not call the breaker.
+	OnStateChange func(from, to BreakerState)
+}
+
+// CircuitBreaker stops calling a datasource that keeps failing and fails fast with
+// ErrCircuitOpen instead, giving it time to recover.
+type CircuitBreaker struct {
+	next Datasource
+	cfg  CircuitBreakerConfig
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	probes   int
+	passed   int
+	openedAt time.Time
+}
+
+func NewCircuitBreaker(next Datasource, cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isFailure
+	}
+
+	return &CircuitBreaker{
+		next: next,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// WithCircuitBreaker wraps a datasource in a CircuitBreaker.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Middleware {
+	return func(next Datasource) Datasource {
+		return NewCircuitBreaker(next, cfg)
+	}
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	return b.state
+}
+
+func (b *CircuitBreaker) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return b.call(func() error {
+		return b.next.Set(ctx, key, value, expiration)
+	})
+}
+
+func (b *CircuitBreaker) SetBatch(ctx context.Context, entries []Entry) error {
+	return b.call(func() error {
+		return setBatch(ctx, b.next, entries)
+	})
+}
+
+func (b *CircuitBreaker) Get(ctx context.Context, key string) (any, error) {
+	var value any
+	err := b.call(func() error {
+		var err error
+		value, err = b.next.Get(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (b *CircuitBreaker) GetInto(ctx context.Context, key string, dst any) error {
+	return b.call(func() error {
+		return GetInto(ctx, b.next, key, dst)
+	})
+}
+
+func (b *CircuitBreaker) GetRaw(ctx context.Context, key string) (string, time.Duration, error) {
+	store, ok := b.next.(RawStore)
+	if !ok {
+		return "", 0, errors.ErrUnsupported
+	}
+	var data string
+	var ttl time.Duration
+	err := b.call(func() error {
+		var err error
+		data, ttl, err = store.GetRaw(ctx, key)
+		return err
+	})
+	return data, ttl, err
+}
+
+func (b *CircuitBreaker) SetRaw(ctx context.Context, key, data string, expiration time.Duration) error {
+	store, ok := b.next.(RawStore)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return b.call(func() error {
+		return store.SetRaw(ctx, key, data, expiration)
+	})
+}
+
+func (b *CircuitBreaker) Delete(ctx context.Context, key string) error {
+	return b.call(func() error {
+		return b.next.Delete(ctx, key)
+	})
+}
+
+func (b *CircuitBreaker) Exists(ctx context.Context, key string) (bool, error) {
+	var found bool
+	err := b.call(func() error {
+		var err error
+		found, err = b.next.Exists(ctx, key)
+		return err
+	})
+	return found, err
+}
+
+func (b *CircuitBreaker) Close(ctx context.Context) error {
+	if closer, ok := b.next.(interface {
+		Close(ctx context.Context) error
+	}); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) call(fn func() error) error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+
+	err := fn()
+	b.release(b.cfg.IsFailure(err))
+	return err
+}
+
+func (b *CircuitBreaker) acquire() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	switch b.state {
+	case StateOpen:
+		return ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return ErrCircuitOpen
+		}
+		b.probes++
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) release(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if failed {
+			b.setState(StateOpen)
+			return
+		}
+		b.passed++
+		if b.passed >= b.cfg.HalfOpenProbes {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+// refresh moves an open circuit to half-open once OpenTimeout has passed.
+// The caller must hold b.mu.
+func (b *CircuitBreaker) refresh() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+}
+
+// setState must be called with b.mu held.
+func (b *CircuitBreaker) setState(state BreakerState) {
+	from := b.state
+	b.state = state
+	b.failures, b.probes, b.passed = 0, 0, 0
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+
+	if b.cfg.OnStateChange != nil && from != state {
+		b.cfg.OnStateChange(from, state)
+	}
+}
+
+func isFailure(err error) bool {
+	var mismatch *TypeMismatchError
+	return err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, context.Canceled) && !errors.As(err, &mismatch)
+}
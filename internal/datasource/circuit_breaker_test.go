@@ -0,0 +1,118 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	ctx := context.Background()
+	diskErr := errors.New("disk is full")
+	database := newMemorySource()
+	database.values["key"] = "value"
+
+	now := time.Unix(0, 0)
+	var transitions []string
+	breaker := NewCircuitBreaker(database, CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := breaker.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+		}
+	}
+	if breaker.State() != StateClosed {
+		t.Fatal("misses should not open the circuit")
+	}
+
+	database.err = diskErr
+	breaker.Get(ctx, "key")
+	breaker.Get(ctx, "key")
+	if breaker.State() != StateOpen {
+		t.Fatalf("State() = %v after consecutive failures, want open", breaker.State())
+	}
+
+	database.err = nil
+	if _, err := breaker.Get(ctx, "key"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	now = now.Add(time.Minute)
+	if breaker.State() != StateHalfOpen {
+		t.Fatalf("State() = %v after OpenTimeout, want half-open", breaker.State())
+	}
+	database.err = diskErr
+	breaker.Get(ctx, "key")
+	if breaker.State() != StateOpen {
+		t.Fatalf("State() = %v after a failed probe, want open", breaker.State())
+	}
+
+	now = now.Add(time.Minute)
+	database.err = nil
+	if got, err := breaker.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want the probe to pass", got, err)
+	}
+	if breaker.State() != StateClosed {
+		t.Fatalf("State() = %v after a successful probe, want closed", breaker.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions = %v, want %v", transitions, want)
+		}
+	}
+}
+
+// staleCache keeps expired values apart from the live ones.
+type staleCache struct {
+	*memorySource
+	stale map[string]any
+}
+
+func (s staleCache) GetStale(ctx context.Context, key string) (any, error) {
+	if value, err := s.memorySource.Get(ctx, key); err == nil {
+		return value, nil
+	}
+	value, ok := s.stale[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func TestLayeredServesStaleWhileOpen(t *testing.T) {
+	ctx := context.Background()
+	cache := staleCache{memorySource: newMemorySource(), stale: map[string]any{"key": "old"}}
+	database := newMemorySource()
+	database.err = errors.New("permission denied")
+	breaker := NewCircuitBreaker(database, CircuitBreakerConfig{FailureThreshold: 1})
+	layered := NewLayered(cache, breaker, LayeredConfig{ServeStale: true})
+
+	if got, err := layered.Get(ctx, "key"); err != nil || got != "old" {
+		t.Fatalf("Get() = %v, %v, want the stale value", got, err)
+	}
+	if breaker.State() != StateOpen {
+		t.Fatal("the failed read should open the circuit")
+	}
+	if got, err := layered.Get(ctx, "key"); err != nil || got != "old" {
+		t.Fatalf("Get() = %v, %v, want the stale value while open", got, err)
+	}
+	if _, err := layered.Get(ctx, "other"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want %v without a stale value", err, ErrCircuitOpen)
+	}
+	if _, ok := cache.values["key"]; ok {
+		t.Fatal("stale values should not be copied back into the cache")
+	}
+}
@@ -0,0 +1,177 @@
+package datasource
+
+//go:generate mockgen -source=codec.go -destination=mocks/codec_mock.go -package=mocks
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrUnsupportedValue = errors.New("value is not supported by the codec")
+
+// Codec converts values to the strings stored by the cache and the database.
+// Both layers must use the same codec for a value to read back the same from either.
+type Codec interface {
+	Encode(value any) (string, error)
+	// Decode stores the value encoded in data into target, a non-nil pointer.
+	Decode(data string, target any) error
+}
+
+// JSONCodec stores values as JSON. Decoding into *any yields the generic JSON types:
+// float64 numbers, map[string]any objects and []any arrays.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (JSONCodec) Decode(data string, target any) error {
+	return json.Unmarshal([]byte(data), target)
+}
+
+// GobCodec stores values as base64 encoded gob, so they decode back to their Go type.
+// Types other than the basic ones must be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Encode(value any) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (GobCodec) Decode(data string, target any) error {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
+
+	var value any
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&value); err != nil {
+		return err
+	}
+	return assign(target, value)
+}
+
+// RawCodec stores strings and byte slices as they are and decodes to a string.
+type RawCodec struct{}
+
+func (RawCodec) Encode(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedValue, value)
+	}
+}
+
+func (RawCodec) Decode(data string, target any) error {
+	switch t := target.(type) {
+	case *[]byte:
+		*t = []byte(data)
+		return nil
+	default:
+		return assign(target, data)
+	}
+}
+
+// assign stores value into the variable target points to.
+func assign(target any, value any) error {
+	if err := checkTarget(target); err != nil {
+		return err
+	}
+
+	elem := reflect.ValueOf(target).Elem()
+	if value == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(elem.Type()) {
+		return fmt.Errorf("stored value is %T", value)
+	}
+	elem.Set(v)
+	return nil
+}
+
+// TypeMismatchError is returned when a stored value cannot be decoded into the requested type.
+type TypeMismatchError struct {
+	Key  string
+	Want string
+	Err  error
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("value of %q cannot be decoded into %s: %v", e.Key, e.Want, e.Err)
+}
+
+func (e *TypeMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder is implemented by datasources storing values with a Codec. GetInto decodes
+// the stored value straight into dst, so types the codec preserves come back as they were.
+type Decoder interface {
+	GetInto(ctx context.Context, key string, dst any) error
+}
+
+// GetInto reads key from source into dst, a non-nil pointer. Sources that are not a
+// Decoder have the result of Get assigned to dst, which must be of a matching type.
+func GetInto(ctx context.Context, source Datasource, key string, dst any) error {
+	if decoder, ok := source.(Decoder); ok {
+		return decoder.GetInto(ctx, key, dst)
+	}
+
+	value, err := source.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return assignInto(key, dst, value)
+}
+
+// DecodeInto decodes data with codec into dst, reporting failures as a TypeMismatchError.
+func DecodeInto(codec Codec, key, data string, dst any) error {
+	if err := checkTarget(dst); err != nil {
+		return err
+	}
+	if err := codec.Decode(data, dst); err != nil {
+		return &TypeMismatchError{Key: key, Want: targetType(dst), Err: err}
+	}
+	return nil
+}
+
+func assignInto(key string, dst any, value any) error {
+	if err := checkTarget(dst); err != nil {
+		return err
+	}
+	if err := assign(dst, value); err != nil {
+		return &TypeMismatchError{Key: key, Want: targetType(dst), Err: err}
+	}
+	return nil
+}
+
+func checkTarget(dst any) error {
+	ptr := reflect.ValueOf(dst)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", dst)
+	}
+	return nil
+}
+
+func targetType(dst any) string {
+	return reflect.TypeOf(dst).Elem().String()
+}
@@ -0,0 +1,63 @@
+package datasource
+
+import (
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type profile struct {
+	Name string
+	Age  int
+}
+
+func init() {
+	gob.Register(profile{})
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	tests := []struct {
+		codec Codec
+		value any
+		want  any
+	}{
+		{JSONCodec{}, "it's, \"quoted\"", "it's, \"quoted\""},
+		{JSONCodec{}, 42, float64(42)},
+		{JSONCodec{}, profile{Name: "Alice", Age: 30}, map[string]any{"Name": "Alice", "Age": float64(30)}},
+		{GobCodec{}, 42, 42},
+		{GobCodec{}, profile{Name: "Alice", Age: 30}, profile{Name: "Alice", Age: 30}},
+		{RawCodec{}, "a,b\nc", "a,b\nc"},
+		{RawCodec{}, []byte("bytes"), "bytes"},
+	}
+
+	for _, tt := range tests {
+		data, err := tt.codec.Encode(tt.value)
+		if err != nil {
+			t.Fatalf("%T.Encode(%v) error = %v", tt.codec, tt.value, err)
+		}
+
+		var got any
+		if err := tt.codec.Decode(data, &got); err != nil {
+			t.Fatalf("%T.Decode(%q) error = %v", tt.codec, data, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%T round trip of %v = %#v, want %#v", tt.codec, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	if _, err := (RawCodec{}).Encode(42); !errors.Is(err, ErrUnsupportedValue) {
+		t.Fatalf("RawCodec.Encode(42) error = %v, want %v", err, ErrUnsupportedValue)
+	}
+
+	data, _ := GobCodec{}.Encode(42)
+	var name string
+	if err := (GobCodec{}).Decode(data, &name); err == nil {
+		t.Fatal("GobCodec.Decode() of an int into a string should fail")
+	}
+	if err := (GobCodec{}).Decode(data, name); err == nil {
+		t.Fatal("GobCodec.Decode() into a non-pointer should fail")
+	}
+}
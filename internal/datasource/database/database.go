@@ -2,43 +2,176 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
 	"own-database-cache/internal/config"
+	"own-database-cache/internal/datasource"
+	pkg "own-database-cache/pkg/cache"
 	db "own-database-cache/pkg/database"
-	"log"
-	"os"
+	"strconv"
 	"time"
 )
 
 type Client struct {
-	db *db.Database
+	dir       string
+	db        *db.Database
+	codec     datasource.Codec
+	filter    *pkg.Cache
+	filterKey string
+	logger    *slog.Logger
+}
+
+type Option func(*Client)
+
+// WithCodec sets the encoding of stored values, JSON by default.
+func WithCodec(codec datasource.Codec) Option {
+	return func(c *Client) {
+		c.codec = codec
+	}
 }
 
-func NewClient(file string) *Client {
-	return &Client{
-		db: db.NewDatabase(file),
+// WithLogger sets the logger of the database, slog.Default() by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
 	}
 }
 
-const configPath = "config.json"
+func NewClient(file string, opts ...Option) *Client {
+	c := &Client{
+		dir:    file,
+		codec:  datasource.JSONCodec{},
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.db = db.NewDatabase(file, db.WithLogger(c.logger))
+	return c
+}
+
+// NewClientFromConfig returns a client over the database directory of cfg.
+func NewClientFromConfig(cfg *config.Config, opts ...Option) *Client {
+	return NewClient(cfg.PathConfig.DatabaseFilePath, opts...)
+}
+
+func (c *Client) Database() *db.Database {
+	return c.db
+}
+
+// EnableBloomFilter makes Get reject keys that were never written without scanning the table.
+// The filter lives in cache under filterKey and is seeded with the keys already stored, so
+// rows must then be written through clients sharing it. While the filter is missing from
+// the cache every lookup reads the table. A filterKey under pkg.InternalPrefix keeps the
+// filter out of the quota over all keys.
+func (c *Client) EnableBloomFilter(ctx context.Context, cache *pkg.Cache, filterKey string) error {
+	rows, err := c.db.Query(ctx, "SELECT key FROM file")
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to read existing keys: %w", err)
+	}
+
+	if len(rows) > 0 {
+		for _, row := range rows[1:] {
+			if _, err := cache.BFAdd(ctx, filterKey, row[0]); err != nil {
+				return fmt.Errorf("failed to seed bloom filter: %w", err)
+			}
+		}
+	}
+
+	c.filter = cache
+	c.filterKey = filterKey
+	return nil
+}
+
+// rawValue is a value that is already encoded and is stored as it is.
+type rawValue string
+
+func (c *Client) encode(value any) (string, error) {
+	if raw, ok := value.(rawValue); ok {
+		return string(raw), nil
+	}
+	return c.codec.Encode(value)
+}
 
 func (c *Client) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
-	config, err := config.LoadConfig(configPath)
+	return c.SetBatch(ctx, []datasource.Entry{{Key: key, Value: value, Expiration: expiration}})
+}
+
+// SetBatch writes all entries in a single transaction, entries marked Deleted are removed.
+func (c *Client) SetBatch(ctx context.Context, entries []datasource.Entry) error {
+	if err := c.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	txn, err := c.db.Begin(ctx)
 	if err != nil {
-		log.Fatalf("Error reading config: %v", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	databaseFile := config.PathConfig.DatabaseFilePath
-	fileName := config.PathConfig.FileName
+	now := time.Now()
+	for _, entry := range entries {
+		// Every write replaces the stored row, so a key has at most one row.
+		err := c.db.Exec(ctx, txn, deleteQuery, entry.Key)
+		if err == nil && !entry.Deleted {
+			var value string
+			value, err = c.encode(entry.Value)
+			if err == nil {
+				err = c.db.Exec(ctx, txn, "INSERT INTO file (key, value, expiresAt) VALUES (?, ?, ?)",
+					entry.Key, value, expiresAt(now, entry.Expiration))
+			}
+		}
+		if err != nil {
+			_ = c.db.Rollback(ctx, txn)
+			return err
+		}
+	}
 
-	if _, err := os.Stat(databaseFile + fileName); os.IsNotExist(err) {
+	if err := c.db.Commit(ctx, txn); err != nil {
+		return err
+	}
+
+	if c.filter != nil {
+		c.addToFilter(ctx, entries)
+	}
+
+	return nil
+}
+
+// addToFilter adds the written keys to the bloom filter. The rows are already committed,
+// so a failure is logged and the filter dropped: lookups read the table until it is seeded again.
+func (c *Client) addToFilter(ctx context.Context, entries []datasource.Entry) {
+	for _, entry := range entries {
+		if entry.Deleted {
+			continue
+		}
+		_, err := c.filter.BFAdd(ctx, c.filterKey, entry.Key)
+		if err == nil {
+			continue
+		}
+
+		c.logger.Error("bloom filter update failed, dropping the filter",
+			"filter", c.filterKey, "key", entry.Key, "error", err)
+		// The write is durable even if the caller has given up.
+		if _, err := c.filter.Delete(context.WithoutCancel(ctx), c.filterKey); err != nil {
+			c.logger.Error("failed to drop the bloom filter", "filter", c.filterKey, "error", err)
+		}
+		return
+	}
+}
+
+func (c *Client) ensureTable(ctx context.Context) error {
+	if _, err := os.Stat(c.dir + "file.csv"); os.IsNotExist(err) {
 		txn, err := c.db.Begin(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to begin transaction: %w", err)
 		}
 
-		createTableQuery := "CREATE TABLE file (key, value) WITH TYPES (string, string)"
+		createTableQuery := "CREATE TABLE file (key, value, expiresAt) WITH TYPES (string, string, int64)"
 		if err := c.db.Exec(ctx, txn, createTableQuery); err != nil {
+			_ = c.db.Rollback(ctx, txn)
 			return fmt.Errorf("failed to create table: %w", err)
 		}
 		if err := c.db.Commit(ctx, txn); err != nil {
@@ -47,32 +180,183 @@ func (c *Client) Set(ctx context.Context, key string, value any, expiration time
 
 	} else if err != nil {
 		return fmt.Errorf("error checking file existence: %w", err)
+	} else {
+		return c.migrateTable(ctx)
+	}
+
+	return nil
+}
+
+// migrateTable adds the expiresAt column to tables written before it existed,
+// their rows never expire.
+func (c *Client) migrateTable(ctx context.Context) error {
+	columns, _, err := c.db.Columns(ctx, "file")
+	if err != nil {
+		return fmt.Errorf("failed to read table structure: %w", err)
+	}
+	for _, column := range columns {
+		if column == "expiresAt" {
+			return nil
+		}
 	}
 
 	txn, err := c.db.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	if err := c.db.Exec(ctx, txn, "ALTER TABLE file ADD COLUMN expiresAt int64"); err != nil {
+		_ = c.db.Rollback(ctx, txn)
+		return fmt.Errorf("failed to migrate table: %w", err)
+	}
+	if err := c.db.Commit(ctx, txn); err != nil {
+		return fmt.Errorf("failed to migrate table: %w", err)
+	}
+	return nil
+}
 
-	value = fmt.Sprintf("\"%s\"", value)
-
-	sql := fmt.Sprintf("INSERT INTO file (key, value) VALUES ('%s', '%v')", key, value)
+func (c *Client) Get(ctx context.Context, key string) (any, error) {
+	var value any
+	if err := c.GetInto(ctx, key, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
 
-	if err := c.db.Exec(ctx, txn, sql); err != nil {
-		_ = c.db.Rollback(ctx, txn)
+// GetInto decodes the value of key into dst, a non-nil pointer.
+func (c *Client) GetInto(ctx context.Context, key string, dst any) error {
+	row, err := c.row(ctx, key, time.Now())
+	if err != nil {
 		return err
 	}
 
-	return c.db.Commit(ctx, txn)
+	err = datasource.DecodeInto(c.codec, key, row[0], dst)
+	var mismatch *datasource.TypeMismatchError
+	if errors.As(err, &mismatch) && !c.decodable(row[0]) {
+		// Tables written before the codec hold values as raw text.
+		return datasource.DecodeInto(datasource.RawCodec{}, key, row[0], dst)
+	}
+	return err
 }
 
-func (c *Client) Get(ctx context.Context, key string) (any, error) {
-	sql := fmt.Sprintf("SELECT key, value FROM file WHERE key = %s", key)
+// decodable reports whether data is the output of the codec, whatever its type.
+func (c *Client) decodable(data string) bool {
+	var value any
+	return c.codec.Decode(data, &value) == nil
+}
 
-	row, err := c.db.QueryRow(ctx, sql)
+// GetRaw returns the value of key as it is stored, without decoding it, and how long
+// it has left to live, zero when it never expires.
+func (c *Client) GetRaw(ctx context.Context, key string) (string, time.Duration, error) {
+	now := time.Now()
+	row, err := c.row(ctx, key, now)
+	if err != nil {
+		return "", 0, err
+	}
+	return row[0], ttl(row, now), nil
+}
+
+// SetRaw stores data under key as it is, data must be a value read with GetRaw or
+// encoded by the codec.
+func (c *Client) SetRaw(ctx context.Context, key, data string, expiration time.Duration) error {
+	return c.Set(ctx, key, rawValue(data), expiration)
+}
+
+// row returns the live [value, expiresAt] row of key.
+func (c *Client) row(ctx context.Context, key string, now time.Time) ([]string, error) {
+	if c.filter != nil {
+		exists, found, err := c.filter.BFLookup(ctx, c.filterKey, key)
+		if err != nil {
+			return nil, err
+		}
+		if found && !exists {
+			return nil, datasource.ErrNotFound
+		}
+	}
+
+	rows, err := c.db.Query(ctx, "SELECT value, expiresAt FROM file WHERE key = ?", key)
+	if isNotFound(err) || (err == nil && len(rows) < 2) {
+		return nil, datasource.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return row[1], nil
+	// Tables written before upserts may still hold several rows for a key, the last one is the newest.
+	row := rows[len(rows)-1]
+	if expired(row, now) {
+		return nil, datasource.ErrNotFound
+	}
+	return row, nil
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	txn, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := c.db.Exec(ctx, txn, deleteQuery, key); err != nil {
+		_ = c.db.Rollback(ctx, txn)
+		return err
+	}
+
+	if err := c.db.Commit(ctx, txn); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := c.Get(ctx, key)
+	if errors.Is(err, datasource.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// expiresAt returns the unix time the entry stops being visible, 0 means never. It is
+// rounded up to the second, so an entry never expires before its TTL.
+func expiresAt(now time.Time, expiration time.Duration) int64 {
+	if expiration <= 0 {
+		return 0
+	}
+	at := now.Add(expiration)
+	if at.Nanosecond() > 0 {
+		return at.Unix() + 1
+	}
+	return at.Unix()
+}
+
+// ttl returns how long a [value, expiresAt] row has left to live at now, zero when
+// it never expires.
+func ttl(row []string, now time.Time) time.Duration {
+	if len(row) < 2 {
+		return 0
+	}
+	at, err := strconv.ParseInt(row[1], 10, 64)
+	if err != nil || at <= 0 {
+		return 0
+	}
+	return time.Unix(at, 0).Sub(now)
+}
+
+// expired reports whether a [value, expiresAt] row is past its expiry. Rows of legacy
+// tables have no expiresAt and never expire.
+func expired(row []string, now time.Time) bool {
+	if len(row) < 2 {
+		return false
+	}
+	at, err := strconv.ParseInt(row[1], 10, 64)
+	return err == nil && at > 0 && at <= now.Unix()
+}
+
+const deleteQuery = "DELETE FROM file WHERE key = ?"
+
+// isNotFound reports whether err means the key is not stored: there is no table yet,
+// the table is empty or no row matched.
+func isNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, db.ErrNoRecords) || errors.Is(err, db.ErrNoRows)
 }
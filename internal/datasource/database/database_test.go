@@ -0,0 +1,286 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"own-database-cache/internal/datasource"
+	pkg "own-database-cache/pkg/cache"
+	db "own-database-cache/pkg/database"
+)
+
+func newClient(t *testing.T) *Client {
+	return NewClient(t.TempDir() + "/")
+}
+
+func TestSetReplacesValue(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	for _, value := range []string{"first", "second"} {
+		if err := client.Set(ctx, "key", value, time.Minute); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	if got, err := client.Get(ctx, "key"); err != nil || got != "second" {
+		t.Fatalf("Get() = %v, %v, want the latest value", got, err)
+	}
+
+	rows, err := db.ReadTable(client.dir + "file.csv")
+	if err != nil {
+		t.Fatalf("ReadTable() error = %v", err)
+	}
+	// The header and the types come first.
+	if len(rows) != 3 || rows[2][0] != "key" {
+		t.Fatalf("table = %q, want a single row for the key", rows)
+	}
+}
+
+func TestExpiredRowsAreHidden(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	if err := client.Set(ctx, "live", "value", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	txn, _ := client.db.Begin(ctx)
+	client.db.Exec(ctx, txn, "INSERT INTO file (key, value, expiresAt) VALUES (?, ?, ?)",
+		"expired", `"value"`, time.Now().Add(-time.Minute).Unix())
+	if err := client.db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if _, err := client.Get(ctx, "expired"); !errors.Is(err, datasource.ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, datasource.ErrNotFound)
+	}
+	if found, err := client.Exists(ctx, "expired"); err != nil || found {
+		t.Fatalf("Exists() = %v, %v for an expired row", found, err)
+	}
+	if got, err := client.Get(ctx, "live"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+}
+
+func TestMigratesTableWithoutExpiresAt(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	txn, _ := client.db.Begin(ctx)
+	client.db.Exec(ctx, txn, "CREATE TABLE file (key, value) WITH TYPES (string, string)")
+	client.db.Exec(ctx, txn, "INSERT INTO file (key, value) VALUES (?, ?)", "old", `"kept"`)
+	if err := client.db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if err := client.Set(ctx, "new", "value", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	columns, _, err := client.db.Columns(ctx, "file")
+	if err != nil || len(columns) != 3 || columns[2] != "expiresAt" {
+		t.Fatalf("Columns() = %q, %v, want expiresAt added", columns, err)
+	}
+	if got, err := client.Get(ctx, "old"); err != nil || got != "kept" {
+		t.Fatalf("Get() = %v, %v, rows written before the migration should never expire", got, err)
+	}
+	if got, err := client.Get(ctx, "new"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+}
+
+func TestSpecialCharacters(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	values := []string{"O'Brien, Jr.", "line one\nline two", `quote " and \ backslash`, "'; DELETE FROM file; --"}
+	for i, value := range values {
+		key := "key, '" + strconv.Itoa(i) + "'\n"
+		if err := client.Set(ctx, key, value, time.Minute); err != nil {
+			t.Fatalf("Set(%q) error = %v", value, err)
+		}
+	}
+
+	for i, value := range values {
+		key := "key, '" + strconv.Itoa(i) + "'\n"
+		if got, err := client.Get(ctx, key); err != nil || got != value {
+			t.Fatalf("Get(%q) = %q, %v, want %q", key, got, err, value)
+		}
+	}
+
+	if err := client.Delete(ctx, "key, '0'\n"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if found, _ := client.Exists(ctx, "key, '0'\n"); found {
+		t.Fatal("Delete() should remove the key holding special characters")
+	}
+	if found, _ := client.Exists(ctx, "key, '1'\n"); !found {
+		t.Fatal("Delete() removed another key")
+	}
+}
+
+func TestGetRaw(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	client.Set(ctx, "expiring", "value", time.Minute)
+	client.Set(ctx, "forever", "value", 0)
+
+	if data, ttl, err := client.GetRaw(ctx, "expiring"); err != nil || data != `"value"` || ttl < 59*time.Second || ttl > time.Minute+time.Second {
+		t.Fatalf("GetRaw() = %q, %v, %v, want the encoded value expiring in about a minute", data, ttl, err)
+	}
+	if _, ttl, err := client.GetRaw(ctx, "forever"); err != nil || ttl != 0 {
+		t.Fatalf("GetRaw() = %v, %v, want 0 for a key that never expires", ttl, err)
+	}
+	if _, _, err := client.GetRaw(ctx, "missing"); !errors.Is(err, datasource.ErrNotFound) {
+		t.Fatalf("GetRaw() error = %v, want %v", err, datasource.ErrNotFound)
+	}
+
+	if err := client.SetRaw(ctx, "copy", `"value"`, 0); err != nil {
+		t.Fatalf("SetRaw() error = %v", err)
+	}
+	if got, err := client.Get(ctx, "copy"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want the value stored raw", got, err)
+	}
+}
+
+type failingCache struct {
+	datasource.Datasource
+}
+
+func (failingCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return errors.New("cache is down")
+}
+
+func TestWriteThroughRollbackKeepsStoredValue(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	// Decoded into an any, the JSON number would come back as a float64 and lose the last digits.
+	const large = int64(1<<60 + 1)
+	client.Set(ctx, "key", large, time.Hour)
+	layered := datasource.NewLayered(failingCache{}, client, datasource.LayeredConfig{Mode: datasource.WriteThrough})
+
+	if err := layered.Set(ctx, "key", int64(0), 0); err == nil {
+		t.Fatal("Set() should fail when the cache write fails")
+	}
+	var got int64
+	if err := client.GetInto(ctx, "key", &got); err != nil || got != large {
+		t.Fatalf("GetInto() = %v, %v, want %v restored exactly", got, err, large)
+	}
+	if _, ttl, _ := client.GetRaw(ctx, "key"); ttl < 59*time.Minute {
+		t.Fatalf("restored TTL = %v, want the hour it had left", ttl)
+	}
+}
+
+func TestBloomFilterMissingFromCache(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+	cache := pkg.NewCache(t.TempDir() + "/cache.csv")
+
+	client.Set(ctx, "key", "value", time.Minute)
+	if err := client.EnableBloomFilter(ctx, cache, "filter"); err != nil {
+		t.Fatalf("EnableBloomFilter() error = %v", err)
+	}
+	if _, err := client.Get(ctx, "missing"); !errors.Is(err, datasource.ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, datasource.ErrNotFound)
+	}
+
+	// A lost filter says nothing about the stored rows.
+	cache.Delete(ctx, "filter")
+	if got, err := client.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want the stored row without a filter", got, err)
+	}
+}
+
+func TestBloomFilterUpdateFailureKeepsWrite(t *testing.T) {
+	ctx := context.Background()
+	var logs bytes.Buffer
+	client := NewClient(t.TempDir()+"/", WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	cache := pkg.NewCache(t.TempDir() + "/cache.csv")
+
+	if err := client.EnableBloomFilter(ctx, cache, "filter"); err != nil {
+		t.Fatalf("EnableBloomFilter() error = %v", err)
+	}
+	// A value of another kind under the filter key makes BFAdd fail.
+	cache.Set(ctx, "filter", "plain", time.Minute)
+
+	if err := client.Set(ctx, "key", "value", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v, the committed write should succeed", err)
+	}
+	if !bytes.Contains(logs.Bytes(), []byte("bloom filter update failed")) {
+		t.Fatalf("logs = %q, want the failed filter update", logs.String())
+	}
+	if got, err := client.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want the written row", got, err)
+	}
+}
+
+func TestLegacyRawValues(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	txn, _ := client.db.Begin(ctx)
+	client.db.Exec(ctx, txn, "CREATE TABLE file (key, value) WITH TYPES (string, string)")
+	client.db.Exec(ctx, txn, "INSERT INTO file (key, value) VALUES (?, ?)", "raw", "best user, expired after 5 seconds")
+	if err := client.db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if got, err := client.Get(ctx, "raw"); err != nil || got != "best user, expired after 5 seconds" {
+		t.Fatalf("Get() = %v, %v, want the raw text", got, err)
+	}
+	var text string
+	if err := client.GetInto(ctx, "raw", &text); err != nil || text != "best user, expired after 5 seconds" {
+		t.Fatalf("GetInto() = %q, %v, want the raw text", text, err)
+	}
+	var number int
+	if err := client.GetInto(ctx, "raw", &number); !errors.As(err, new(*datasource.TypeMismatchError)) {
+		t.Fatalf("GetInto() error = %v, want a type mismatch", err)
+	}
+
+	// Values written by the codec still have to match the requested type.
+	client.Set(ctx, "encoded", "text", 0)
+	if err := client.GetInto(ctx, "encoded", &number); !errors.As(err, new(*datasource.TypeMismatchError)) {
+		t.Fatalf("GetInto() error = %v, want a type mismatch", err)
+	}
+}
+
+func TestSubSecondTTL(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	if err := client.Set(ctx, "key", "value", 300*time.Millisecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, err := client.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, a sub-second TTL should not expire at once", got, err)
+	}
+	if _, ttl, err := client.GetRaw(ctx, "key"); err != nil || ttl < 300*time.Millisecond {
+		t.Fatalf("GetRaw() = %v, %v, want at least the TTL set", ttl, err)
+	}
+
+	time.Sleep(1300 * time.Millisecond)
+	if _, err := client.Get(ctx, "key"); !errors.Is(err, datasource.ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v once the TTL is over", err, datasource.ErrNotFound)
+	}
+}
+
+func TestExpiresAtRoundsUp(t *testing.T) {
+	now := time.Unix(100, 700*int64(time.Millisecond))
+	for expiration, want := range map[time.Duration]int64{
+		0:                      0,
+		200 * time.Millisecond: 101,
+		300 * time.Millisecond: 101,
+		time.Second:            102,
+	} {
+		if got := expiresAt(now, expiration); got != want {
+			t.Errorf("expiresAt(%v) = %d, want %d", expiration, got, want)
+		}
+	}
+}
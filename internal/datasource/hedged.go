@@ -0,0 +1,201 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type ReadMode int
+
+const (
+	// ReadParallel asks every source at once.
+	ReadParallel ReadMode = iota
+	// ReadHedged asks the sources one after another, moving on to the next one when
+	// the previous did not answer within HedgeDelay or failed.
+	ReadHedged
+)
+
+type HedgedConfig struct {
+	Mode ReadMode
+	// HedgeDelay is how long ReadHedged waits for a source before asking the next one.
+	HedgeDelay time.Duration
+}
+
+// Hedged reads from several replicas of the same data and returns the first
+// successful answer, cancelling the reads still in flight. Writes go to every replica.
+type Hedged struct {
+	sources []Datasource
+	cfg     HedgedConfig
+}
+
+func NewHedged(sources []Datasource, cfg HedgedConfig) *Hedged {
+	if cfg.HedgeDelay <= 0 {
+		cfg.HedgeDelay = 50 * time.Millisecond
+	}
+	return &Hedged{sources: sources, cfg: cfg}
+}
+
+func (h *Hedged) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return h.each(ctx, func(ctx context.Context, source Datasource) error {
+		return source.Set(ctx, key, value, expiration)
+	})
+}
+
+func (h *Hedged) Delete(ctx context.Context, key string) error {
+	return h.each(ctx, func(ctx context.Context, source Datasource) error {
+		return source.Delete(ctx, key)
+	})
+}
+
+func (h *Hedged) Get(ctx context.Context, key string) (any, error) {
+	return h.read(ctx, func(ctx context.Context, source Datasource) (any, error) {
+		return source.Get(ctx, key)
+	})
+}
+
+// GetInto decodes the first successful answer into dst, every read decodes into
+// its own value so concurrent reads do not share dst.
+func (h *Hedged) GetInto(ctx context.Context, key string, dst any) error {
+	if err := checkTarget(dst); err != nil {
+		return err
+	}
+
+	elem := reflect.ValueOf(dst).Elem()
+	value, err := h.read(ctx, func(ctx context.Context, source Datasource) (any, error) {
+		v := reflect.New(elem.Type())
+		if err := GetInto(ctx, source, key, v.Interface()); err != nil {
+			return nil, err
+		}
+		return v.Elem(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	elem.Set(value.(reflect.Value))
+	return nil
+}
+
+// Exists reports true as soon as one source has key.
+func (h *Hedged) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := h.read(ctx, func(ctx context.Context, source Datasource) (any, error) {
+		found, err := source.Exists(ctx, key)
+		if err == nil && !found {
+			err = ErrNotFound
+		}
+		return nil, err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *Hedged) Close(ctx context.Context) error {
+	return h.each(ctx, func(ctx context.Context, source Datasource) error {
+		if closer, ok := source.(interface {
+			Close(ctx context.Context) error
+		}); ok {
+			return closer.Close(ctx)
+		}
+		return nil
+	})
+}
+
+// each runs fn on all sources concurrently and joins their errors.
+func (h *Hedged) each(ctx context.Context, fn func(ctx context.Context, source Datasource) error) error {
+	errs := make([]error, len(h.sources))
+
+	var wg sync.WaitGroup
+	for i, source := range h.sources {
+		wg.Add(1)
+		go func(i int, source Datasource) {
+			defer wg.Done()
+			errs[i] = fn(ctx, source)
+		}(i, source)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// read starts fn on the sources according to the read mode and returns the first
+// successful result. It fails with ErrNotFound if every source missed.
+func (h *Hedged) read(ctx context.Context, fn func(ctx context.Context, source Datasource) (any, error)) (any, error) {
+	if len(h.sources) == 0 {
+		return nil, ErrNotFound
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		value any
+		err   error
+	}
+	results := make(chan result, len(h.sources))
+
+	next, running := 0, 0
+	start := func() {
+		source := h.sources[next]
+		next++
+		running++
+		go func() {
+			value, err := fn(ctx, source)
+			results <- result{value: value, err: err}
+		}()
+	}
+
+	hedge := time.NewTimer(h.cfg.HedgeDelay)
+	defer hedge.Stop()
+	restartHedge := func() {
+		if !hedge.Stop() {
+			select {
+			case <-hedge.C:
+			default:
+			}
+		}
+		hedge.Reset(h.cfg.HedgeDelay)
+	}
+
+	start()
+	for h.cfg.Mode == ReadParallel && next < len(h.sources) {
+		start()
+	}
+
+	var errs []error
+	for running > 0 {
+		var hedgeC <-chan time.Time
+		if next < len(h.sources) {
+			hedgeC = hedge.C
+		}
+
+		select {
+		case r := <-results:
+			running--
+			if r.err == nil {
+				return r.value, nil
+			}
+			errs = append(errs, r.err)
+			if next < len(h.sources) {
+				start()
+				restartHedge()
+			}
+		case <-hedgeC:
+			start()
+			restartHedge()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotFound) {
+			return nil, errors.Join(errs...)
+		}
+	}
+	return nil, ErrNotFound
+}
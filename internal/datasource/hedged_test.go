@@ -0,0 +1,128 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// delayedSource answers after delay unless the read is cancelled first.
+type delayedSource struct {
+	*memorySource
+	delay     time.Duration
+	calls     atomic.Int32
+	cancelled atomic.Int32
+}
+
+func newDelayedSource(delay time.Duration, values map[string]any) *delayedSource {
+	source := &delayedSource{memorySource: newMemorySource(), delay: delay}
+	for key, value := range values {
+		source.values[key] = value
+	}
+	return source
+}
+
+func (d *delayedSource) Get(ctx context.Context, key string) (any, error) {
+	d.calls.Add(1)
+	select {
+	case <-time.After(d.delay):
+		return d.memorySource.Get(ctx, key)
+	case <-ctx.Done():
+		d.cancelled.Add(1)
+		return nil, ctx.Err()
+	}
+}
+
+func TestHedgedParallelReturnsFastest(t *testing.T) {
+	slow := newDelayedSource(time.Second, map[string]any{"key": "slow"})
+	fast := newDelayedSource(0, map[string]any{"key": "fast"})
+	hedged := NewHedged([]Datasource{slow, fast}, HedgedConfig{Mode: ReadParallel})
+
+	started := time.Now()
+	if got, err := hedged.Get(context.Background(), "key"); err != nil || got != "fast" {
+		t.Fatalf("Get() = %v, %v, want the fast replica", got, err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Fatalf("Get() took %v, should not wait for the slow replica", elapsed)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for slow.cancelled.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the slow read should be cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHedgedAsksNextAfterDelay(t *testing.T) {
+	ctx := context.Background()
+	slow := newDelayedSource(time.Second, map[string]any{"key": "slow"})
+	fast := newDelayedSource(0, map[string]any{"key": "fast"})
+	hedged := NewHedged([]Datasource{slow, fast}, HedgedConfig{Mode: ReadHedged, HedgeDelay: 10 * time.Millisecond})
+
+	if got, err := hedged.Get(ctx, "key"); err != nil || got != "fast" {
+		t.Fatalf("Get() = %v, %v, want the hedged read", got, err)
+	}
+
+	first := newDelayedSource(0, map[string]any{"key": "first"})
+	second := newDelayedSource(0, map[string]any{"key": "second"})
+	hedged = NewHedged([]Datasource{first, second}, HedgedConfig{Mode: ReadHedged, HedgeDelay: time.Second})
+	if got, err := hedged.Get(ctx, "key"); err != nil || got != "first" {
+		t.Fatalf("Get() = %v, %v, want the first replica", got, err)
+	}
+	if second.calls.Load() != 0 {
+		t.Fatal("a quick answer should not be hedged")
+	}
+}
+
+func TestHedgedFailsOverWithoutWaiting(t *testing.T) {
+	ctx := context.Background()
+	broken := newMemorySource()
+	broken.err = errors.New("disk is slow")
+	healthy := newMemorySource()
+	healthy.values["key"] = "value"
+	hedged := NewHedged([]Datasource{broken, healthy}, HedgedConfig{Mode: ReadHedged, HedgeDelay: time.Hour})
+
+	if got, err := hedged.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want the healthy replica", got, err)
+	}
+	if _, err := hedged.Get(ctx, "missing"); !errors.Is(err, broken.err) {
+		t.Fatalf("Get() error = %v, want the replica error", err)
+	}
+
+	broken.err = nil
+	if _, err := hedged.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+	if found, err := hedged.Exists(ctx, "key"); err != nil || !found {
+		t.Fatalf("Exists() = %v, %v", found, err)
+	}
+}
+
+func TestHedgedWritesEveryReplica(t *testing.T) {
+	ctx := context.Background()
+	first, second := newMemorySource(), newMemorySource()
+	hedged := NewHedged([]Datasource{first, second}, HedgedConfig{Mode: ReadParallel})
+
+	if err := hedged.Set(ctx, "key", 42, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if first.values["key"] != 42 || second.values["key"] != 42 {
+		t.Fatal("Set() should write every replica")
+	}
+
+	var got int
+	if err := hedged.GetInto(ctx, "key", &got); err != nil || got != 42 {
+		t.Fatalf("GetInto() = %v, %v", got, err)
+	}
+
+	if err := hedged.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(first.values) != 0 || len(second.values) != 0 {
+		t.Fatal("Delete() should remove the key from every replica")
+	}
+}
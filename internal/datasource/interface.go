@@ -1,11 +1,68 @@
 package datasource
 
+//go:generate mockgen -source=interface.go -destination=mocks/datasource_mock.go -package=mocks
+
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned by Get when the key is missing or has expired.
+var ErrNotFound = errors.New("key not found")
+
 type Datasource interface {
 	Set(ctx context.Context, key string, value any, expiration time.Duration) error
 	Get(ctx context.Context, key string) (any, error)
+	// Delete removes key, deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+type Entry struct {
+	Key        string
+	Value      any
+	Expiration time.Duration
+	// Deleted marks the removal of Key rather than a write.
+	Deleted bool
+}
+
+// BatchSetter is implemented by datasources able to store several entries at once,
+// write-behind flushes use it to write a whole batch in one transaction.
+type BatchSetter interface {
+	SetBatch(ctx context.Context, entries []Entry) error
+}
+
+// StaleGetter is implemented by caches that can return values which have expired
+// recently, to be served while the database is unavailable.
+type StaleGetter interface {
+	GetStale(ctx context.Context, key string) (any, error)
+}
+
+// RawStore is implemented by datasources able to read and write values in their stored
+// form, along with how long they have left to live, zero meaning never. Write-through
+// rollbacks use it to restore a value exactly as it was, with its original lifetime.
+type RawStore interface {
+	GetRaw(ctx context.Context, key string) (string, time.Duration, error)
+	SetRaw(ctx context.Context, key, data string, expiration time.Duration) error
+}
+
+// setBatch stores entries with SetBatch if source supports it, one by one otherwise.
+func setBatch(ctx context.Context, source Datasource, entries []Entry) error {
+	if batcher, ok := source.(BatchSetter); ok {
+		return batcher.SetBatch(ctx, entries)
+	}
+
+	for _, entry := range entries {
+		var err error
+		if entry.Deleted {
+			err = source.Delete(ctx, entry.Key)
+		} else {
+			err = source.Set(ctx, entry.Key, entry.Value, entry.Expiration)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
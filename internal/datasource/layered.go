@@ -0,0 +1,364 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+type ErrorPolicy int
+
+const (
+	// FailOnError returns the error of the layer to the caller.
+	FailOnError ErrorPolicy = iota
+	// IgnoreErrors carries on as if the layer had missed.
+	IgnoreErrors
+)
+
+type WriteMode int
+
+const (
+	// CacheAside writes the database and then the cache.
+	CacheAside WriteMode = iota
+	// WriteThrough writes the database and then the cache while holding the key, so
+	// readers never see only one of them updated; a failed cache write undoes the database one.
+	WriteThrough
+	// WriteBehind acknowledges writes once they are in the cache and flushes them to the
+	// database in the background in batches.
+	WriteBehind
+)
+
+type LayeredConfig struct {
+	Mode        WriteMode
+	WriteBehind WriteBehindConfig
+	// CacheTTL is the expiration of values copied into the cache after a miss,
+	// zero leaves the choice to the cache.
+	CacheTTL time.Duration
+	// OnCacheError decides whether a failing cache fails the call or is bypassed.
+	OnCacheError ErrorPolicy
+	// OnDatabaseError decides whether a failing database read fails the call or is
+	// reported as a miss. Database writes always fail the call: it is the source of truth.
+	OnDatabaseError ErrorPolicy
+	// ServeStale makes a failing database read fall back to the expired copy of the
+	// value if the cache is a StaleGetter and still holds one. Together with a
+	// CircuitBreaker around the database it keeps reads working during an outage.
+	ServeStale bool
+	// Logger receives the errors the policies bypass and the write-behind batches
+	// dropped after all retries, slog.Default() when nil.
+	Logger *slog.Logger
+}
+
+// Layered reads from the cache first and falls back to the database on a miss,
+// copying the value into the cache. Writes follow the configured WriteMode.
+type Layered struct {
+	cache    Datasource
+	database Datasource
+	cfg      LayeredConfig
+	locks    *keyLocks
+	behind   *writeBehind
+}
+
+func NewLayered(cache, database Datasource, cfg LayeredConfig) *Layered {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	l := &Layered{
+		cache:    cache,
+		database: database,
+		cfg:      cfg,
+		locks:    newKeyLocks(),
+	}
+	if cfg.Mode == WriteBehind {
+		l.behind = newWriteBehind(database, cfg.WriteBehind, cfg.Logger)
+	}
+	return l
+}
+
+func (l *Layered) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	switch l.cfg.Mode {
+	case WriteThrough:
+		return l.setThrough(ctx, key, value, expiration)
+	case WriteBehind:
+		return l.setBehind(ctx, key, value, expiration)
+	}
+	return l.setAside(ctx, key, value, expiration)
+}
+
+// setAside writes the database then the cache. It holds the key lock like a read
+// repopulating the cache after a miss, so that read cannot store the value it read
+// before this write over the new one.
+func (l *Layered) setAside(ctx context.Context, key string, value any, expiration time.Duration) error {
+	unlock, err := l.locks.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := l.database.Set(ctx, key, value, expiration); err != nil {
+		return fmt.Errorf("database set: %w", err)
+	}
+
+	if err := l.cache.Set(ctx, key, value, expiration); err != nil {
+		// The cache may still hold the previous value, which must not outlive the write.
+		if deleteErr := l.cache.Delete(ctx, key); deleteErr != nil {
+			err = errors.Join(err, fmt.Errorf("cache delete: %w", deleteErr))
+		}
+		if l.cfg.OnCacheError == FailOnError {
+			return fmt.Errorf("cache set: %w", err)
+		}
+		l.bypass(ctx, "cache", OpSet, key, err)
+	}
+
+	return nil
+}
+
+func (l *Layered) setThrough(ctx context.Context, key string, value any, expiration time.Duration) error {
+	unlock, err := l.locks.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The previous value is restored exactly as it was stored, with the lifetime it had
+	// left, or removed when the database cannot read it back that way.
+	store, _ := l.database.(RawStore)
+	previous, previousTTL, previousErr := "", time.Duration(0), errors.ErrUnsupported
+	if store != nil {
+		previous, previousTTL, previousErr = store.GetRaw(ctx, key)
+		if previousErr != nil && !errors.Is(previousErr, ErrNotFound) && !errors.Is(previousErr, errors.ErrUnsupported) {
+			return fmt.Errorf("database get: %w", previousErr)
+		}
+	}
+
+	if err := l.database.Set(ctx, key, value, expiration); err != nil {
+		return fmt.Errorf("database set: %w", err)
+	}
+
+	if err := l.cache.Set(ctx, key, value, expiration); err != nil {
+		var restoreErr error
+		if previousErr == nil {
+			restoreErr = store.SetRaw(ctx, key, previous, previousTTL)
+		} else {
+			restoreErr = l.database.Delete(ctx, key)
+		}
+
+		err = fmt.Errorf("cache set: %w", err)
+		if restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("database restore: %w", restoreErr))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (l *Layered) setBehind(ctx context.Context, key string, value any, expiration time.Duration) error {
+	unlock, err := l.locks.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := l.cache.Set(ctx, key, value, expiration); err != nil {
+		return fmt.Errorf("cache set: %w", err)
+	}
+
+	return l.behind.enqueue(Entry{Key: key, Value: value, Expiration: expiration})
+}
+
+// Flush waits until all pending write-behind writes reach the database.
+func (l *Layered) Flush(ctx context.Context) error {
+	if l.behind == nil {
+		return nil
+	}
+	return l.behind.flush(ctx)
+}
+
+// Close flushes pending write-behind writes and stops the background writer.
+func (l *Layered) Close(ctx context.Context) error {
+	if l.behind == nil {
+		return nil
+	}
+	return l.behind.close(ctx)
+}
+
+func (l *Layered) Get(ctx context.Context, key string) (any, error) {
+	var value any
+	if err := l.GetInto(ctx, key, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetInto works like Get but decodes the value into dst, a non-nil pointer.
+func (l *Layered) GetInto(ctx context.Context, key string, dst any) error {
+	var mismatch *TypeMismatchError
+
+	err := GetInto(ctx, l.cache, key, dst)
+	if err == nil || errors.As(err, &mismatch) {
+		return err
+	}
+	if !errors.Is(err, ErrNotFound) {
+		if l.cfg.OnCacheError == FailOnError {
+			return fmt.Errorf("cache get: %w", err)
+		}
+		l.bypass(ctx, "cache", OpGet, key, err)
+	}
+
+	unlock, err := l.locks.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if entry, ok := l.pending(key); ok {
+		if entry.Deleted {
+			return ErrNotFound
+		}
+		return assignInto(key, dst, entry.Value)
+	}
+
+	err = GetInto(ctx, l.database, key, dst)
+	if errors.Is(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if errors.As(err, &mismatch) {
+		return err
+	}
+	if err != nil {
+		if stale, ok := l.stale(ctx, key); ok {
+			l.bypass(ctx, "database", OpGet, key, err)
+			return assignInto(key, dst, stale)
+		}
+		if l.cfg.OnDatabaseError == IgnoreErrors {
+			l.bypass(ctx, "database", OpGet, key, err)
+			return ErrNotFound
+		}
+		return fmt.Errorf("database get: %w", err)
+	}
+
+	value := reflect.ValueOf(dst).Elem().Interface()
+	if err := l.cache.Set(ctx, key, value, l.cfg.CacheTTL); err != nil {
+		if l.cfg.OnCacheError == FailOnError {
+			return fmt.Errorf("cache set: %w", err)
+		}
+		l.bypass(ctx, "cache", OpSet, key, err)
+	}
+
+	return nil
+}
+
+func (l *Layered) Delete(ctx context.Context, key string) error {
+	unlock, err := l.locks.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if l.cfg.Mode == WriteBehind {
+		if err := l.cache.Delete(ctx, key); err != nil {
+			return fmt.Errorf("cache delete: %w", err)
+		}
+		return l.behind.enqueue(Entry{Key: key, Deleted: true})
+	}
+
+	if err := l.database.Delete(ctx, key); err != nil {
+		return fmt.Errorf("database delete: %w", err)
+	}
+
+	if err := l.cache.Delete(ctx, key); err != nil {
+		if l.cfg.Mode == WriteThrough || l.cfg.OnCacheError == FailOnError {
+			return fmt.Errorf("cache delete: %w", err)
+		}
+		l.bypass(ctx, "cache", OpDelete, key, err)
+	}
+
+	return nil
+}
+
+func (l *Layered) Exists(ctx context.Context, key string) (bool, error) {
+	found, err := l.cache.Exists(ctx, key)
+	if err != nil {
+		if l.cfg.OnCacheError == FailOnError {
+			return false, fmt.Errorf("cache exists: %w", err)
+		}
+		l.bypass(ctx, "cache", OpExists, key, err)
+	}
+	if err == nil && found {
+		return true, nil
+	}
+
+	if entry, ok := l.pending(key); ok {
+		return !entry.Deleted, nil
+	}
+
+	found, err = l.database.Exists(ctx, key)
+	if err != nil {
+		if l.cfg.OnDatabaseError == IgnoreErrors {
+			l.bypass(ctx, "database", OpExists, key, err)
+			return false, nil
+		}
+		return false, fmt.Errorf("database exists: %w", err)
+	}
+
+	return found, nil
+}
+
+// bypass logs an error of layer the policies let the call carry on without.
+func (l *Layered) bypass(ctx context.Context, layer, op, key string, err error) {
+	l.cfg.Logger.LogAttrs(ctx, slog.LevelWarn, "layer error bypassed",
+		slog.String("layer", layer), slog.String("op", op), slog.String("key", key), slog.Any("error", err))
+}
+
+// stale returns the expired cached value of key when ServeStale is enabled.
+func (l *Layered) stale(ctx context.Context, key string) (any, bool) {
+	getter, ok := l.cache.(StaleGetter)
+	if !l.cfg.ServeStale || !ok {
+		return nil, false
+	}
+
+	value, err := getter.GetStale(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// pending returns the write-behind operation on key that has not reached the database yet.
+func (l *Layered) pending(key string) (Entry, bool) {
+	if l.behind == nil {
+		return Entry{}, false
+	}
+	return l.behind.pending(key)
+}
+
+// keyLocks serializes operations on the same key without a lock per key.
+type keyLocks [64]chan struct{}
+
+func newKeyLocks() *keyLocks {
+	var k keyLocks
+	for i := range k {
+		k[i] = make(chan struct{}, 1)
+	}
+	return &k
+}
+
+// lock waits for the lock of key and returns the function releasing it, or the
+// context error if ctx is done first.
+func (k *keyLocks) lock(ctx context.Context, key string) (func(), error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	mu := k[h.Sum32()%uint32(len(k))]
+	select {
+	case mu <- struct{}{}:
+		return func() { <-mu }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
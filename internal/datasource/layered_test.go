@@ -0,0 +1,449 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memorySource struct {
+	mu          sync.Mutex
+	values      map[string]any
+	expirations map[string]time.Duration
+	err         error
+}
+
+func newMemorySource() *memorySource {
+	return &memorySource{values: make(map[string]any), expirations: make(map[string]time.Duration)}
+}
+
+func (m *memorySource) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	m.values[key] = value
+	m.expirations[key] = expiration
+	return nil
+}
+
+func (m *memorySource) Get(ctx context.Context, key string) (any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return nil, m.err
+	}
+	value, ok := m.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func (m *memorySource) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.values, key)
+	delete(m.expirations, key)
+	return nil
+}
+
+// GetRaw returns string values as they are, with the expiration key was set with:
+// the source does not expire keys itself.
+func (m *memorySource) GetRaw(ctx context.Context, key string) (string, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return "", 0, m.err
+	}
+	value, ok := m.values[key]
+	if !ok {
+		return "", 0, ErrNotFound
+	}
+	data, err := RawCodec{}.Encode(value)
+	return data, m.expirations[key], err
+}
+
+func (m *memorySource) SetRaw(ctx context.Context, key, data string, expiration time.Duration) error {
+	return m.Set(ctx, key, data, expiration)
+}
+
+func (m *memorySource) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return false, m.err
+	}
+	_, ok := m.values[key]
+	return ok, nil
+}
+
+func TestLayeredReadsThroughAndRepopulates(t *testing.T) {
+	cache, database := newMemorySource(), newMemorySource()
+	layered := NewLayered(cache, database, LayeredConfig{})
+	ctx := context.Background()
+
+	if err := layered.Set(ctx, "key", "value", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if cache.values["key"] != "value" || database.values["key"] != "value" {
+		t.Fatal("Set() should write both layers")
+	}
+
+	delete(cache.values, "key")
+	got, err := layered.Get(ctx, "key")
+	if err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want value from the database", got, err)
+	}
+	if cache.values["key"] != "value" {
+		t.Fatal("Get() should copy the database value into the cache")
+	}
+
+	database.values["key"] = "stale"
+	if got, _ := layered.Get(ctx, "key"); got != "value" {
+		t.Fatalf("Get() = %v, want the cached value", got)
+	}
+}
+
+func TestLayeredErrorPolicies(t *testing.T) {
+	ctx := context.Background()
+	cacheErr := errors.New("cache is down")
+	databaseErr := errors.New("database is down")
+
+	cache, database := newMemorySource(), newMemorySource()
+	database.values["key"] = "value"
+	cache.err = cacheErr
+
+	strict := NewLayered(cache, database, LayeredConfig{})
+	if _, err := strict.Get(ctx, "key"); !errors.Is(err, cacheErr) {
+		t.Fatalf("Get() error = %v, want %v", err, cacheErr)
+	}
+	if err := strict.Set(ctx, "key", "value", 0); !errors.Is(err, cacheErr) {
+		t.Fatalf("Set() error = %v, want %v", err, cacheErr)
+	}
+
+	var log bytes.Buffer
+	lenient := NewLayered(cache, database, LayeredConfig{
+		OnCacheError:    IgnoreErrors,
+		OnDatabaseError: IgnoreErrors,
+		Logger:          slog.New(slog.NewTextHandler(&log, nil)),
+	})
+	if got, err := lenient.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want the database value", got, err)
+	}
+
+	database.err = databaseErr
+	if got, err := lenient.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() = %v, %v, want a miss", got, err)
+	}
+	if err := lenient.Set(ctx, "key", "value", 0); !errors.Is(err, databaseErr) {
+		t.Fatalf("Set() error = %v, want %v", err, databaseErr)
+	}
+
+	for _, want := range []string{`layer=cache op=get key=key error="cache is down"`, `layer=database op=get key=key error="database is down"`} {
+		if !strings.Contains(log.String(), want) {
+			t.Fatalf("log = %q, want the bypassed error %s", log.String(), want)
+		}
+	}
+}
+
+type failingCache struct {
+	*memorySource
+}
+
+func (f failingCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return errors.New("cache is full")
+}
+
+func TestLayeredCacheAsideDropsStaleValue(t *testing.T) {
+	cache, database := newMemorySource(), newMemorySource()
+	layered := NewLayered(failingCache{cache}, database, LayeredConfig{OnCacheError: IgnoreErrors})
+	ctx := context.Background()
+
+	cache.values["key"] = "old"
+	if err := layered.Set(ctx, "key", "new", 0); err != nil {
+		t.Fatalf("Set() error = %v, the cache error should be ignored", err)
+	}
+	if _, ok := cache.values["key"]; ok {
+		t.Fatal("Set() should drop the cached value it failed to replace")
+	}
+	if got, err := layered.Get(ctx, "key"); err != nil || got != "new" {
+		t.Fatalf("Get() = %v, %v, want the new value", got, err)
+	}
+}
+
+// slowReads blocks Get until release is closed, after reporting it started on reading.
+type slowReads struct {
+	*memorySource
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (s slowReads) Get(ctx context.Context, key string) (any, error) {
+	value, err := s.memorySource.Get(ctx, key)
+	close(s.reading)
+	<-s.release
+	return value, err
+}
+
+func TestLayeredCacheAsideMissDoesNotOverwriteWrite(t *testing.T) {
+	cache, database := newMemorySource(), newMemorySource()
+	database.values["key"] = "old"
+	source := slowReads{memorySource: database, reading: make(chan struct{}), release: make(chan struct{})}
+	layered := NewLayered(cache, source, LayeredConfig{})
+	ctx := context.Background()
+
+	read := make(chan error)
+	go func() {
+		_, err := layered.Get(ctx, "key")
+		read <- err
+	}()
+	<-source.reading
+
+	// The write lands while the miss holds the old value.
+	written := make(chan error)
+	go func() {
+		written <- layered.Set(ctx, "key", "new", 0)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(source.release)
+
+	if err := <-read; err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if cache.values["key"] != "new" {
+		t.Fatalf("cached value = %v, want the value written after the miss", cache.values["key"])
+	}
+}
+
+func TestLayeredWriteThroughRestoresDatabase(t *testing.T) {
+	database := newMemorySource()
+	database.Set(context.Background(), "key", "old", time.Hour)
+	layered := NewLayered(failingCache{newMemorySource()}, database, LayeredConfig{Mode: WriteThrough})
+	ctx := context.Background()
+
+	if err := layered.Set(ctx, "key", "new", time.Minute); err == nil {
+		t.Fatal("Set() should fail when the cache write fails")
+	}
+	if database.values["key"] != "old" || database.expirations["key"] != time.Hour {
+		t.Fatalf("database value = %v expiring in %v, want the previous value restored with its TTL",
+			database.values["key"], database.expirations["key"])
+	}
+
+	if err := layered.Set(ctx, "missing", "new", 0); err == nil {
+		t.Fatal("Set() should fail when the cache write fails")
+	}
+	if _, ok := database.values["missing"]; ok {
+		t.Fatal("Set() should remove the key that did not exist before")
+	}
+}
+
+func TestLayeredWriteThroughDeletesWithoutTTL(t *testing.T) {
+	database := newMemorySource()
+	database.values["key"] = "old"
+	// Embedding the interface hides GetRaw, so the previous value cannot be read back as stored.
+	layered := NewLayered(failingCache{newMemorySource()}, struct{ Datasource }{database}, LayeredConfig{Mode: WriteThrough})
+
+	if err := layered.Set(context.Background(), "key", "new", time.Minute); err == nil {
+		t.Fatal("Set() should fail when the cache write fails")
+	}
+	if _, ok := database.values["key"]; ok {
+		t.Fatal("Set() should remove a value it cannot restore as it was stored")
+	}
+}
+
+func TestLayeredDeleteAndExists(t *testing.T) {
+	cache, database := newMemorySource(), newMemorySource()
+	layered := NewLayered(cache, database, LayeredConfig{})
+	ctx := context.Background()
+
+	layered.Set(ctx, "key", "value", 0)
+	delete(cache.values, "key")
+
+	if found, err := layered.Exists(ctx, "key"); err != nil || !found {
+		t.Fatalf("Exists() = %v, %v, want the database copy", found, err)
+	}
+
+	if err := layered.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if found, err := layered.Exists(ctx, "key"); err != nil || found {
+		t.Fatalf("Exists() = %v, %v after Delete()", found, err)
+	}
+	if _, err := layered.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+type batchSource struct {
+	*memorySource
+	batches  [][]Entry
+	failures int
+}
+
+func (b *batchSource) SetBatch(ctx context.Context, entries []Entry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures > 0 {
+		b.failures--
+		return errors.New("disk is busy")
+	}
+	b.batches = append(b.batches, entries)
+	for _, entry := range entries {
+		if entry.Deleted {
+			delete(b.values, entry.Key)
+			continue
+		}
+		b.values[entry.Key] = entry.Value
+	}
+	return nil
+}
+
+func TestLayeredWriteBehind(t *testing.T) {
+	cache := newMemorySource()
+	database := &batchSource{memorySource: newMemorySource(), failures: 1}
+	layered := NewLayered(cache, database, LayeredConfig{
+		Mode: WriteBehind,
+		WriteBehind: WriteBehindConfig{
+			QueueSize:     4,
+			BatchSize:     10,
+			FlushInterval: time.Hour,
+			MaxRetries:    1,
+			RetryBackoff:  time.Millisecond,
+		},
+	})
+	ctx := context.Background()
+
+	for _, value := range []string{"a", "b", "c"} {
+		if err := layered.Set(ctx, "key", value, 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	if err := layered.Set(ctx, "other", "d", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if cache.values["key"] != "c" {
+		t.Fatal("Set() should write the cache synchronously")
+	}
+	if err := layered.Set(ctx, "overflow", "e", 0); !errors.Is(err, ErrWriteQueueFull) {
+		t.Fatalf("Set() error = %v, want %v", err, ErrWriteQueueFull)
+	}
+
+	if err := layered.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	database.mu.Lock()
+	batches := database.batches
+	database.mu.Unlock()
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("batches = %v, want a single batch with the last value of each key", batches)
+	}
+	if database.values["key"] != "c" || database.values["other"] != "d" {
+		t.Fatalf("database values = %v", database.values)
+	}
+
+	database.mu.Lock()
+	database.batches = nil
+	database.mu.Unlock()
+
+	if err := layered.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := layered.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want the pending delete to hide the database value", err)
+	}
+	if err := layered.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if found, _ := database.Exists(ctx, "key"); found {
+		t.Fatal("Flush() should delete the key from the database")
+	}
+
+	if err := layered.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := layered.Set(ctx, "key", "f", 0); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Set() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestLatestEntriesShortensExpiration(t *testing.T) {
+	queuedAt := time.Now()
+	batch := []queued{
+		{Entry: Entry{Key: "short", Value: "a", Expiration: time.Second}, seq: 1, deadline: queuedAt.Add(time.Second)},
+		{Entry: Entry{Key: "long", Value: "b", Expiration: time.Minute}, seq: 2, deadline: queuedAt.Add(time.Minute)},
+		{Entry: Entry{Key: "forever", Value: "c"}, seq: 3},
+	}
+
+	entries := latestEntries(batch, queuedAt.Add(2*time.Second))
+	if len(entries) != 3 {
+		t.Fatalf("latestEntries() = %v, want 3 entries", entries)
+	}
+	if !entries[0].Deleted {
+		t.Fatalf("entries[0] = %+v, want an expired write to become a deletion", entries[0])
+	}
+	if entries[1].Expiration != 58*time.Second {
+		t.Fatalf("entries[1].Expiration = %v, want the time left since it was queued", entries[1].Expiration)
+	}
+	if entries[2].Expiration != 0 || entries[2].Deleted {
+		t.Fatalf("entries[2] = %+v, want a write that never expires", entries[2])
+	}
+}
+
+func TestLayeredGetInto(t *testing.T) {
+	cache, database := newMemorySource(), newMemorySource()
+	database.values["count"] = 42
+	layered := NewLayered(cache, database, LayeredConfig{})
+	ctx := context.Background()
+
+	var count int
+	if err := layered.GetInto(ctx, "count", &count); err != nil || count != 42 {
+		t.Fatalf("GetInto() = %v, %v, want 42", count, err)
+	}
+	if cache.values["count"] != 42 {
+		t.Fatal("GetInto() should copy the database value into the cache")
+	}
+
+	var name string
+	var mismatch *TypeMismatchError
+	if err := layered.GetInto(ctx, "count", &name); !errors.As(err, &mismatch) {
+		t.Fatalf("GetInto() error = %v, want a type mismatch", err)
+	}
+	if err := layered.GetInto(ctx, "count", name); err == nil {
+		t.Fatal("GetInto() into a non-pointer should fail")
+	}
+}
+
+func TestLayeredLockRespectsContext(t *testing.T) {
+	layered := NewLayered(newMemorySource(), newMemorySource(), LayeredConfig{Mode: WriteThrough})
+	unlock, err := layered.locks.lock(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := layered.Set(ctx, "key", "value", 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Set() error = %v, want %v while the key is locked", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,288 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"own-database-cache/pkg/ratelimit"
+)
+
+// Operation names passed to middlewares and used as metric labels.
+const (
+	OpSet      = "set"
+	OpSetBatch = "set_batch"
+	OpGet      = "get"
+	OpDelete   = "delete"
+	OpExists   = "exists"
+)
+
+// Middleware decorates a datasource with behavior shared by all of its operations.
+type Middleware func(Datasource) Datasource
+
+// Chain wraps source with middlewares, the first one is the outermost:
+// Chain(s, WithLogging(nil), WithRetry(p)) logs once per call, not per attempt.
+func Chain(source Datasource, middlewares ...Middleware) Datasource {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		source = middlewares[i](source)
+	}
+	return source
+}
+
+// interceptor runs call, the operation on the wrapped datasource, and may inspect or
+// repeat it. call can be invoked with a derived context.
+type interceptor func(ctx context.Context, op, key string, call func(ctx context.Context) error) error
+
+func intercept(fn interceptor) Middleware {
+	return func(next Datasource) Datasource {
+		return &intercepted{next: next, intercept: fn}
+	}
+}
+
+// intercepted routes every operation of next through an interceptor. It forwards
+// SetBatch, GetInto, TTL and Close, so wrapping a datasource does not hide them.
+type intercepted struct {
+	next      Datasource
+	intercept interceptor
+}
+
+func (i *intercepted) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return i.intercept(ctx, OpSet, key, func(ctx context.Context) error {
+		return i.next.Set(ctx, key, value, expiration)
+	})
+}
+
+func (i *intercepted) SetBatch(ctx context.Context, entries []Entry) error {
+	return i.intercept(ctx, OpSetBatch, "", func(ctx context.Context) error {
+		return setBatch(ctx, i.next, entries)
+	})
+}
+
+func (i *intercepted) Get(ctx context.Context, key string) (any, error) {
+	var value any
+	err := i.intercept(ctx, OpGet, key, func(ctx context.Context) error {
+		var err error
+		value, err = i.next.Get(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (i *intercepted) GetInto(ctx context.Context, key string, dst any) error {
+	return i.intercept(ctx, OpGet, key, func(ctx context.Context) error {
+		return GetInto(ctx, i.next, key, dst)
+	})
+}
+
+func (i *intercepted) GetRaw(ctx context.Context, key string) (string, time.Duration, error) {
+	store, ok := i.next.(RawStore)
+	if !ok {
+		return "", 0, errors.ErrUnsupported
+	}
+	var data string
+	var ttl time.Duration
+	err := i.intercept(ctx, OpGet, key, func(ctx context.Context) error {
+		var err error
+		data, ttl, err = store.GetRaw(ctx, key)
+		return err
+	})
+	return data, ttl, err
+}
+
+func (i *intercepted) SetRaw(ctx context.Context, key, data string, expiration time.Duration) error {
+	store, ok := i.next.(RawStore)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return i.intercept(ctx, OpSet, key, func(ctx context.Context) error {
+		return store.SetRaw(ctx, key, data, expiration)
+	})
+}
+
+func (i *intercepted) Delete(ctx context.Context, key string) error {
+	return i.intercept(ctx, OpDelete, key, func(ctx context.Context) error {
+		return i.next.Delete(ctx, key)
+	})
+}
+
+func (i *intercepted) Exists(ctx context.Context, key string) (bool, error) {
+	var found bool
+	err := i.intercept(ctx, OpExists, key, func(ctx context.Context) error {
+		var err error
+		found, err = i.next.Exists(ctx, key)
+		return err
+	})
+	return found, err
+}
+
+func (i *intercepted) Close(ctx context.Context) error {
+	if closer, ok := i.next.(interface {
+		Close(ctx context.Context) error
+	}); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+// WithLogging logs every operation with its duration at info level, failures at
+// error level; misses are not failures. A nil logger logs to slog.Default().
+func WithLogging(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return intercept(func(ctx context.Context, op, key string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		attrs := []slog.Attr{slog.String("op", op), slog.String("key", key), slog.Duration("took", time.Since(start))}
+
+		switch {
+		case err == nil:
+			logger.LogAttrs(ctx, slog.LevelInfo, "datasource call", append(attrs, slog.String("result", "ok"))...)
+		case errors.Is(err, ErrNotFound):
+			logger.LogAttrs(ctx, slog.LevelInfo, "datasource call", append(attrs, slog.String("result", "not found"))...)
+		default:
+			logger.LogAttrs(ctx, slog.LevelError, "datasource call", append(attrs, slog.String("result", "failed"), slog.Any("error", err))...)
+		}
+		return err
+	})
+}
+
+type OpStats struct {
+	Calls   int64
+	Errors  int64
+	Misses  int64
+	Latency time.Duration
+}
+
+// Metrics collects per-operation counters, one Metrics can be shared by several datasources.
+type Metrics struct {
+	mu  sync.Mutex
+	ops map[string]OpStats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{ops: make(map[string]OpStats)}
+}
+
+// Snapshot returns a copy of the counters keyed by operation.
+func (m *Metrics) Snapshot() map[string]OpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]OpStats, len(m.ops))
+	for op, stats := range m.ops {
+		snapshot[op] = stats
+	}
+	return snapshot
+}
+
+func (m *Metrics) observe(op string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.ops[op]
+	stats.Calls++
+	stats.Latency += elapsed
+	switch {
+	case errors.Is(err, ErrNotFound):
+		stats.Misses++
+	case err != nil:
+		stats.Errors++
+	}
+	m.ops[op] = stats
+}
+
+func WithMetrics(metrics *Metrics) Middleware {
+	return intercept(func(ctx context.Context, op, key string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		metrics.observe(op, time.Since(start), err)
+		return err
+	})
+}
+
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, it doubles up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable decides whether err is worth another attempt, by default everything
+	// except misses and type mismatches is retried. Nothing is retried once the caller's context is done.
+	Retryable func(err error) bool
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     50 * time.Millisecond,
+	MaxBackoff:  time.Second,
+}
+
+func WithRetry(policy RetryPolicy) Middleware {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = retryable
+	}
+
+	return intercept(func(ctx context.Context, op, key string, call func(ctx context.Context) error) error {
+		backoff := policy.Backoff
+		for attempt := 1; ; attempt++ {
+			err := call(ctx)
+			if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) || ctx.Err() != nil {
+				return err
+			}
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			}
+
+			backoff *= 2
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	})
+}
+
+func retryable(err error) bool {
+	var mismatch *TypeMismatchError
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrRateLimited) && !errors.As(err, &mismatch)
+}
+
+// WithTimeout sets a deadline of timeout on the context of every call, the wrapped
+// datasource is expected to give up once it passes. Placed after WithRetry it limits
+// each attempt, placed before it limits the call as a whole.
+func WithTimeout(timeout time.Duration) Middleware {
+	return intercept(func(ctx context.Context, op, key string, call func(ctx context.Context) error) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return call(ctx)
+	})
+}
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// WithRateLimit takes a token from limiter under limitKey for every operation and
+// fails the operation with ErrRateLimited when there is none left.
+func WithRateLimit(limiter ratelimit.Limiter, limitKey string) Middleware {
+	return intercept(func(ctx context.Context, op, key string, call func(ctx context.Context) error) error {
+		result, err := limiter.Allow(ctx, limitKey)
+		if err != nil {
+			return fmt.Errorf("rate limit: %w", err)
+		}
+		if !result.Allowed {
+			return fmt.Errorf("%w, retry after %v", ErrRateLimited, result.RetryAfter)
+		}
+		return call(ctx)
+	})
+}
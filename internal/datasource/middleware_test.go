@@ -0,0 +1,124 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakySource fails the first failures calls with err.
+type flakySource struct {
+	*memorySource
+	failures int
+	calls    int
+	err      error
+}
+
+func (f *flakySource) Get(ctx context.Context, key string) (any, error) {
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		return nil, f.err
+	}
+	return f.memorySource.Get(ctx, key)
+}
+
+// slowSource blocks until the context of the call is done.
+type slowSource struct {
+	*memorySource
+}
+
+func (s slowSource) Get(ctx context.Context, key string) (any, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	source := &flakySource{memorySource: newMemorySource(), failures: 2, err: errors.New("disk is busy")}
+	source.values["key"] = "value"
+	retried := Chain(source, WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+
+	if got, err := retried.Get(ctx, "key"); err != nil || got != "value" {
+		t.Fatalf("Get() = %v, %v, want value after retries", got, err)
+	}
+	if source.calls != 3 {
+		t.Fatalf("calls = %d, want 3", source.calls)
+	}
+
+	source.calls, source.failures = 0, 5
+	if _, err := retried.Get(ctx, "key"); !errors.Is(err, source.err) {
+		t.Fatalf("Get() error = %v, want %v", err, source.err)
+	}
+	if source.calls != 3 {
+		t.Fatalf("calls = %d, want MaxAttempts", source.calls)
+	}
+
+	source.calls, source.failures = 0, 0
+	if _, err := retried.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+	if source.calls != 1 {
+		t.Fatalf("calls = %d, misses should not be retried", source.calls)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	source := Chain(slowSource{newMemorySource()},
+		WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}),
+		WithTimeout(10*time.Millisecond),
+	)
+
+	start := time.Now()
+	if _, err := source.Get(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("Get() took %v, want two attempts of 10ms", elapsed)
+	}
+}
+
+func TestWithMetricsAndLogging(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	metrics := NewMetrics()
+	source := Chain(newMemorySource(), WithLogging(slog.New(slog.NewTextHandler(&buf, nil))), WithMetrics(metrics))
+
+	source.Set(ctx, "key", "value", 0)
+	source.Get(ctx, "key")
+	source.Get(ctx, "missing")
+
+	stats := metrics.Snapshot()
+	if stats[OpSet].Calls != 1 || stats[OpGet].Calls != 2 || stats[OpGet].Misses != 1 || stats[OpGet].Errors != 0 {
+		t.Fatalf("metrics = %+v", stats)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], `msg="datasource call" op=get key=missing`) || !strings.Contains(lines[2], `result="not found"`) {
+		t.Fatalf("log = %q", buf.String())
+	}
+}
+
+func TestChainKeepsBatchesAndClose(t *testing.T) {
+	ctx := context.Background()
+	database := &batchSource{memorySource: newMemorySource()}
+	metrics := NewMetrics()
+	layered := NewLayered(newMemorySource(), Chain(database, WithMetrics(metrics)), LayeredConfig{
+		Mode:        WriteBehind,
+		WriteBehind: WriteBehindConfig{FlushInterval: time.Hour},
+	})
+	source := Chain(layered, WithMetrics(metrics))
+
+	source.Set(ctx, "key", "value", 0)
+	if closer, ok := source.(interface{ Close(context.Context) error }); !ok || closer.Close(ctx) != nil {
+		t.Fatal("Close() should reach the layered datasource")
+	}
+	if len(database.batches) != 1 || metrics.Snapshot()[OpSetBatch].Calls != 1 {
+		t.Fatalf("batches = %v, want the write-behind batch to go through SetBatch", database.batches)
+	}
+}
@@ -0,0 +1,101 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: codec.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCodec is a mock of Codec interface.
+type MockCodec struct {
+	ctrl     *gomock.Controller
+	recorder *MockCodecMockRecorder
+}
+
+// MockCodecMockRecorder is the mock recorder for MockCodec.
+type MockCodecMockRecorder struct {
+	mock *MockCodec
+}
+
+// NewMockCodec creates a new mock instance.
+func NewMockCodec(ctrl *gomock.Controller) *MockCodec {
+	mock := &MockCodec{ctrl: ctrl}
+	mock.recorder = &MockCodecMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCodec) EXPECT() *MockCodecMockRecorder {
+	return m.recorder
+}
+
+// Decode mocks base method.
+func (m *MockCodec) Decode(data string, target any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decode", data, target)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Decode indicates an expected call of Decode.
+func (mr *MockCodecMockRecorder) Decode(data, target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decode", reflect.TypeOf((*MockCodec)(nil).Decode), data, target)
+}
+
+// Encode mocks base method.
+func (m *MockCodec) Encode(value any) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Encode", value)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Encode indicates an expected call of Encode.
+func (mr *MockCodecMockRecorder) Encode(value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Encode", reflect.TypeOf((*MockCodec)(nil).Encode), value)
+}
+
+// MockDecoder is a mock of Decoder interface.
+type MockDecoder struct {
+	ctrl     *gomock.Controller
+	recorder *MockDecoderMockRecorder
+}
+
+// MockDecoderMockRecorder is the mock recorder for MockDecoder.
+type MockDecoderMockRecorder struct {
+	mock *MockDecoder
+}
+
+// NewMockDecoder creates a new mock instance.
+func NewMockDecoder(ctrl *gomock.Controller) *MockDecoder {
+	mock := &MockDecoder{ctrl: ctrl}
+	mock.recorder = &MockDecoderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDecoder) EXPECT() *MockDecoderMockRecorder {
+	return m.recorder
+}
+
+// GetInto mocks base method.
+func (m *MockDecoder) GetInto(ctx context.Context, key string, dst any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInto", ctx, key, dst)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetInto indicates an expected call of GetInto.
+func (mr *MockDecoderMockRecorder) GetInto(ctx, key, dst interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInto", reflect.TypeOf((*MockDecoder)(nil).GetInto), ctx, key, dst)
+}
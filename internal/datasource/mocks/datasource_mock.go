@@ -0,0 +1,223 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: interface.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	datasource "own-database-cache/internal/datasource"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDatasource is a mock of Datasource interface.
+type MockDatasource struct {
+	ctrl     *gomock.Controller
+	recorder *MockDatasourceMockRecorder
+}
+
+// MockDatasourceMockRecorder is the mock recorder for MockDatasource.
+type MockDatasourceMockRecorder struct {
+	mock *MockDatasource
+}
+
+// NewMockDatasource creates a new mock instance.
+func NewMockDatasource(ctrl *gomock.Controller) *MockDatasource {
+	mock := &MockDatasource{ctrl: ctrl}
+	mock.recorder = &MockDatasourceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDatasource) EXPECT() *MockDatasourceMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockDatasource) Delete(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockDatasourceMockRecorder) Delete(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockDatasource)(nil).Delete), ctx, key)
+}
+
+// Exists mocks base method.
+func (m *MockDatasource) Exists(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockDatasourceMockRecorder) Exists(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockDatasource)(nil).Exists), ctx, key)
+}
+
+// Get mocks base method.
+func (m *MockDatasource) Get(ctx context.Context, key string) (any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key)
+	ret0, _ := ret[0].(any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockDatasourceMockRecorder) Get(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockDatasource)(nil).Get), ctx, key)
+}
+
+// Set mocks base method.
+func (m *MockDatasource) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, key, value, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockDatasourceMockRecorder) Set(ctx, key, value, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockDatasource)(nil).Set), ctx, key, value, expiration)
+}
+
+// MockBatchSetter is a mock of BatchSetter interface.
+type MockBatchSetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchSetterMockRecorder
+}
+
+// MockBatchSetterMockRecorder is the mock recorder for MockBatchSetter.
+type MockBatchSetterMockRecorder struct {
+	mock *MockBatchSetter
+}
+
+// NewMockBatchSetter creates a new mock instance.
+func NewMockBatchSetter(ctrl *gomock.Controller) *MockBatchSetter {
+	mock := &MockBatchSetter{ctrl: ctrl}
+	mock.recorder = &MockBatchSetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchSetter) EXPECT() *MockBatchSetterMockRecorder {
+	return m.recorder
+}
+
+// SetBatch mocks base method.
+func (m *MockBatchSetter) SetBatch(ctx context.Context, entries []datasource.Entry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBatch", ctx, entries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBatch indicates an expected call of SetBatch.
+func (mr *MockBatchSetterMockRecorder) SetBatch(ctx, entries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBatch", reflect.TypeOf((*MockBatchSetter)(nil).SetBatch), ctx, entries)
+}
+
+// MockStaleGetter is a mock of StaleGetter interface.
+type MockStaleGetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockStaleGetterMockRecorder
+}
+
+// MockStaleGetterMockRecorder is the mock recorder for MockStaleGetter.
+type MockStaleGetterMockRecorder struct {
+	mock *MockStaleGetter
+}
+
+// NewMockStaleGetter creates a new mock instance.
+func NewMockStaleGetter(ctrl *gomock.Controller) *MockStaleGetter {
+	mock := &MockStaleGetter{ctrl: ctrl}
+	mock.recorder = &MockStaleGetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStaleGetter) EXPECT() *MockStaleGetterMockRecorder {
+	return m.recorder
+}
+
+// GetStale mocks base method.
+func (m *MockStaleGetter) GetStale(ctx context.Context, key string) (any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStale", ctx, key)
+	ret0, _ := ret[0].(any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStale indicates an expected call of GetStale.
+func (mr *MockStaleGetterMockRecorder) GetStale(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStale", reflect.TypeOf((*MockStaleGetter)(nil).GetStale), ctx, key)
+}
+
+// MockRawStore is a mock of RawStore interface.
+type MockRawStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockRawStoreMockRecorder
+}
+
+// MockRawStoreMockRecorder is the mock recorder for MockRawStore.
+type MockRawStoreMockRecorder struct {
+	mock *MockRawStore
+}
+
+// NewMockRawStore creates a new mock instance.
+func NewMockRawStore(ctrl *gomock.Controller) *MockRawStore {
+	mock := &MockRawStore{ctrl: ctrl}
+	mock.recorder = &MockRawStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRawStore) EXPECT() *MockRawStoreMockRecorder {
+	return m.recorder
+}
+
+// GetRaw mocks base method.
+func (m *MockRawStore) GetRaw(ctx context.Context, key string) (string, time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRaw", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(time.Duration)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetRaw indicates an expected call of GetRaw.
+func (mr *MockRawStoreMockRecorder) GetRaw(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRaw", reflect.TypeOf((*MockRawStore)(nil).GetRaw), ctx, key)
+}
+
+// SetRaw mocks base method.
+func (m *MockRawStore) SetRaw(ctx context.Context, key, data string, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRaw", ctx, key, data, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRaw indicates an expected call of SetRaw.
+func (mr *MockRawStoreMockRecorder) SetRaw(ctx, key, data, expiration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRaw", reflect.TypeOf((*MockRawStore)(nil).SetRaw), ctx, key, data, expiration)
+}
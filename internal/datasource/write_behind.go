@@ -0,0 +1,265 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrWriteQueueFull = errors.New("write-behind queue is full")
+	ErrClosed         = errors.New("datasource is closed")
+)
+
+type WriteBehindConfig struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	// MaxRetries is the number of extra attempts for a failed batch, the delay between
+	// them starts at RetryBackoff and doubles every time.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// OnError receives the batches that could not be written after all retries.
+	OnError func(entries []Entry, err error)
+}
+
+type queued struct {
+	Entry
+	seq uint64
+	// deadline is when a write with an expiration runs out, fixed when it is queued
+	// so the time spent waiting for the flush is not added to its lifetime.
+	deadline time.Time
+}
+
+// writeBehind queues writes and flushes them to the database from a single goroutine.
+type writeBehind struct {
+	database Datasource
+	cfg      WriteBehindConfig
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	closed  bool
+	seq     uint64
+	latest  map[string]queued
+	size    atomic.Int64
+	queue   chan queued
+	flushes chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newWriteBehind(database Datasource, cfg WriteBehindConfig, logger *slog.Logger) *writeBehind {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+
+	w := &writeBehind{
+		database: database,
+		cfg:      cfg,
+		logger:   logger,
+		latest:   make(map[string]queued),
+		queue:    make(chan queued, cfg.QueueSize),
+		flushes:  make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+
+	return w
+}
+
+func (w *writeBehind) enqueue(entry Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	// size counts entries until they are written, so the bound also covers the
+	// batch being collected, and the channel never blocks.
+	if w.size.Add(1) > int64(w.cfg.QueueSize) {
+		w.size.Add(-1)
+		return ErrWriteQueueFull
+	}
+
+	w.seq++
+	item := queued{Entry: entry, seq: w.seq}
+	if !entry.Deleted && entry.Expiration > 0 {
+		item.deadline = time.Now().Add(entry.Expiration)
+	}
+	w.latest[entry.Key] = item
+	w.queue <- item
+	return nil
+}
+
+// pending returns the latest queued operation on key that is not written yet.
+func (w *writeBehind) pending(key string) (Entry, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	item, ok := w.latest[key]
+	return item.Entry, ok
+}
+
+// flush waits until everything queued so far has been written.
+func (w *writeBehind) flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case w.flushes <- ack:
+	case <-w.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting writes and waits until the queue is flushed.
+func (w *writeBehind) close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *writeBehind) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]queued, 0, w.cfg.BatchSize)
+	for {
+		select {
+		case entry := <-w.queue:
+			batch = append(batch, entry)
+			if len(batch) >= w.cfg.BatchSize {
+				w.write(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.write(batch)
+			batch = batch[:0]
+		case ack := <-w.flushes:
+			w.write(w.drain(batch))
+			batch = batch[:0]
+			close(ack)
+		case <-w.stop:
+			w.write(w.drain(batch))
+			return
+		}
+	}
+}
+
+// drain moves everything waiting in the queue into batch, writing full batches on the way.
+func (w *writeBehind) drain(batch []queued) []queued {
+	for {
+		select {
+		case entry := <-w.queue:
+			batch = append(batch, entry)
+			if len(batch) >= w.cfg.BatchSize {
+				w.write(batch)
+				batch = batch[:0]
+			}
+		default:
+			return batch
+		}
+	}
+}
+
+func (w *writeBehind) write(batch []queued) {
+	if len(batch) == 0 {
+		return
+	}
+	defer w.written(batch)
+	entries := latestEntries(batch, time.Now())
+
+	backoff := w.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.store(entries)
+		if err == nil {
+			return
+		}
+		if attempt >= w.cfg.MaxRetries {
+			w.logger.Error("write-behind batch dropped", "entries", len(entries), "attempts", attempt+1, "error", err)
+			if w.cfg.OnError != nil {
+				w.cfg.OnError(entries, err)
+			}
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// written forgets the operations of batch unless a newer one on the same key is queued.
+func (w *writeBehind) written(batch []queued) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, item := range batch {
+		if w.latest[item.Key].seq == item.seq {
+			delete(w.latest, item.Key)
+		}
+	}
+	w.size.Add(-int64(len(batch)))
+}
+
+func (w *writeBehind) store(entries []Entry) error {
+	return setBatch(context.Background(), w.database, entries)
+}
+
+// latestEntries keeps only the last operation on every key, preserving their order.
+// Expirations are shortened to what is left of them at now, and writes that have
+// already run out become deletions.
+func latestEntries(batch []queued, now time.Time) []Entry {
+	last := make(map[string]int, len(batch))
+	for i, item := range batch {
+		last[item.Key] = i
+	}
+
+	entries := make([]Entry, 0, len(last))
+	for i, item := range batch {
+		if last[item.Key] != i {
+			continue
+		}
+		entry := item.Entry
+		if !item.deadline.IsZero() {
+			entry.Expiration = item.deadline.Sub(now)
+			if entry.Expiration <= 0 {
+				entry = Entry{Key: entry.Key, Deleted: true}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formats of the log output.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+	level, ok := levels[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q, want one of debug, info, warn, error", s)
+	}
+	return level, nil
+}
+
+func CheckFormat(format string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("unknown log format %q, want %s or %s", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// New returns a logger writing the records at or above level to out as text or JSON.
+// Pass a *slog.LevelVar to change the level while the logger is in use.
+func New(out io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	if err := CheckFormat(format); err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	if format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(out, options)), nil
+	}
+	return slog.New(slog.NewTextHandler(out, options)), nil
+}
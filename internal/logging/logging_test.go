@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, level)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	logger.Info("skipped")
+	level.Set(slog.LevelInfo)
+	logger.Info("logged", "key", "value")
+
+	if got := buf.String(); strings.Contains(got, "skipped") || !strings.Contains(got, `"msg":"logged","key":"value"`) {
+		t.Fatalf("log = %q, want the record logged after the level changed only", got)
+	}
+
+	if _, err := New(&buf, "xml", level); err == nil {
+		t.Fatal("New() should reject an unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("WARN"); err != nil || level != slog.LevelWarn {
+		t.Fatalf("ParseLevel() = %v, %v, want %v", level, err, slog.LevelWarn)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("ParseLevel() should reject an unknown level")
+	}
+}
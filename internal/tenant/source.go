@@ -0,0 +1,96 @@
+package tenant
+
+import (
+	"context"
+	"time"
+
+	"own-database-cache/internal/datasource"
+	"own-database-cache/internal/datasource/cache"
+)
+
+// prefixed keeps the cache entries of a tenant under its own key prefix.
+type prefixed struct {
+	source *cache.Client
+	prefix string
+}
+
+func (p *prefixed) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	return p.source.Set(ctx, p.prefix+key, value, expiration)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (any, error) {
+	return p.source.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) GetInto(ctx context.Context, key string, dst any) error {
+	return p.source.GetInto(ctx, p.prefix+key, dst)
+}
+
+func (p *prefixed) GetStale(ctx context.Context, key string) (any, error) {
+	return p.source.GetStale(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.source.Delete(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Exists(ctx context.Context, key string) (bool, error) {
+	return p.source.Exists(ctx, p.prefix+key)
+}
+
+// router forwards every call to the datasource of the tenant in its context.
+type router struct {
+	registry *Registry
+}
+
+func (r *router) source(ctx context.Context) (datasource.Datasource, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return r.registry.Source(id)
+}
+
+func (r *router) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	source, err := r.source(ctx)
+	if err != nil {
+		return err
+	}
+	return source.Set(ctx, key, value, expiration)
+}
+
+func (r *router) Get(ctx context.Context, key string) (any, error) {
+	source, err := r.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return source.Get(ctx, key)
+}
+
+func (r *router) GetInto(ctx context.Context, key string, dst any) error {
+	source, err := r.source(ctx)
+	if err != nil {
+		return err
+	}
+	return datasource.GetInto(ctx, source, key, dst)
+}
+
+func (r *router) Delete(ctx context.Context, key string) error {
+	source, err := r.source(ctx)
+	if err != nil {
+		return err
+	}
+	return source.Delete(ctx, key)
+}
+
+func (r *router) Exists(ctx context.Context, key string) (bool, error) {
+	source, err := r.source(ctx)
+	if err != nil {
+		return false, err
+	}
+	return source.Exists(ctx, key)
+}
+
+func (r *router) Close(ctx context.Context) error {
+	return r.registry.Close(ctx)
+}
@@ -0,0 +1,172 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sync"
+
+	"own-database-cache/internal/datasource"
+	"own-database-cache/internal/datasource/cache"
+	"own-database-cache/internal/datasource/database"
+	pkg "own-database-cache/pkg/cache"
+	"own-database-cache/pkg/ratelimit"
+)
+
+var (
+	ErrNoTenant  = errors.New("no tenant in context")
+	ErrInvalidID = errors.New("invalid tenant id")
+)
+
+// validID keeps tenant ids safe to use in cache keys and directory names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type contextKey struct{}
+
+// WithTenant returns a context the multi-tenant datasource serves as tenant id.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Quota limits a tenant, zero limits are not enforced.
+type Quota struct {
+	// MaxEntries and MaxBytes limit the live cache items of the tenant, bytes count
+	// the length of the keys and values.
+	MaxEntries int
+	MaxBytes   int64
+	// OpsPerSecond limits the operations of the tenant, allowing bursts of Burst
+	// operations, at least one.
+	OpsPerSecond float64
+	Burst        int
+}
+
+type Config struct {
+	// DatabaseDir holds a directory per tenant, named after its id.
+	DatabaseDir string
+	// Quota applies to the tenants missing from Quotas.
+	Quota  Quota
+	Quotas map[string]Quota
+	// Layered configures the datasource of every tenant, except for its Logger.
+	Layered datasource.LayeredConfig
+	// Logger, slog.Default() when nil, is used by the databases and datasources of the
+	// tenants with their id attached.
+	Logger *slog.Logger
+}
+
+// Registry hosts several tenants in one process. The tenants share the cache under
+// separate key prefixes and each has its own database directory.
+type Registry struct {
+	cache *cache.Client
+	cfg   Config
+	// limits holds the rate limiter buckets in memory, so rate limited calls do not
+	// rewrite the cache file and are not refused by its quotas.
+	limits *pkg.Cache
+
+	mu      sync.Mutex
+	tenants map[string]datasource.Datasource
+}
+
+func NewRegistry(cacheClient *cache.Client, cfg Config) *Registry {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &Registry{
+		cache:   cacheClient,
+		cfg:     cfg,
+		limits:  pkg.NewCache("", pkg.WithLogger(cfg.Logger)),
+		tenants: make(map[string]datasource.Datasource),
+	}
+}
+
+// Source returns the datasource of tenant id, creating it on first use.
+func (r *Registry) Source(id string) (datasource.Datasource, error) {
+	if !validID.MatchString(id) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if source, ok := r.tenants[id]; ok {
+		return source, nil
+	}
+
+	source, err := r.open(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tenant %s: %w", id, err)
+	}
+	r.tenants[id] = source
+	return source, nil
+}
+
+func (r *Registry) open(id string) (datasource.Datasource, error) {
+	dir := r.cfg.DatabaseDir + id + "/"
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	quota, ok := r.cfg.Quotas[id]
+	if !ok {
+		quota = r.cfg.Quota
+	}
+
+	prefix := "tenant:" + id + ":"
+	if quota.MaxEntries > 0 || quota.MaxBytes > 0 {
+		r.cache.Cache().SetQuota(pkg.Quota{Prefix: prefix, MaxEntries: quota.MaxEntries, MaxBytes: quota.MaxBytes})
+	}
+
+	logger := r.cfg.Logger.With("tenant", id)
+	layeredCfg := r.cfg.Layered
+	layeredCfg.Logger = logger
+
+	databaseClient := database.NewClient(dir, database.WithLogger(logger))
+	layered := datasource.NewLayered(&prefixed{source: r.cache, prefix: prefix}, databaseClient, layeredCfg)
+	if quota.OpsPerSecond <= 0 {
+		return layered, nil
+	}
+
+	burst := quota.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	limiter, err := ratelimit.NewTokenBucket(r.limits, ratelimit.TokenBucketConfig{
+		Rate:   quota.OpsPerSecond,
+		Burst:  burst,
+		Prefix: "ratelimit:tenant:",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return datasource.Chain(layered, datasource.WithRateLimit(limiter, id)), nil
+}
+
+// Router returns a datasource serving the tenant found in the context of each call.
+func (r *Registry) Router() datasource.Datasource {
+	return &router{registry: r}
+}
+
+// Close flushes and stops the datasources of all tenants.
+func (r *Registry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for id, source := range r.tenants {
+		if closer, ok := source.(interface {
+			Close(ctx context.Context) error
+		}); ok {
+			if err := closer.Close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("tenant %s: %w", id, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
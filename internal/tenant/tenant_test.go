@@ -0,0 +1,141 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"own-database-cache/internal/datasource"
+	"own-database-cache/internal/datasource/cache"
+	pkg "own-database-cache/pkg/cache"
+)
+
+func newRegistry(t *testing.T, cfg Config) *Registry {
+	dir := t.TempDir()
+	cfg.DatabaseDir = dir + "/tenants/"
+	registry := NewRegistry(cache.NewClient(dir+"/cache.csv"), cfg)
+	t.Cleanup(func() { registry.Close(context.Background()) })
+	return registry
+}
+
+func TestTenantsAreIsolated(t *testing.T) {
+	registry := newRegistry(t, Config{})
+	router := registry.Router()
+	alice := WithTenant(context.Background(), "alice")
+	bob := WithTenant(context.Background(), "bob")
+
+	if err := router.Set(alice, "key", "alice's", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := router.Set(bob, "key", "bob's", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if got, err := router.Get(alice, "key"); err != nil || got != "alice's" {
+		t.Fatalf("Get() = %v, %v for alice", got, err)
+	}
+	if got, err := router.Get(bob, "key"); err != nil || got != "bob's" {
+		t.Fatalf("Get() = %v, %v for bob", got, err)
+	}
+	if err := router.Delete(bob, "key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if found, _ := router.Exists(alice, "key"); !found {
+		t.Fatal("deleting a key of bob removed the key of alice")
+	}
+
+	for _, id := range []string{"alice", "bob"} {
+		if _, err := os.Stat(registry.cfg.DatabaseDir + id + "/file.csv"); err != nil {
+			t.Fatalf("database of %s: %v", id, err)
+		}
+	}
+	if found, _ := registry.cache.Exists(context.Background(), "key"); found {
+		t.Fatal("tenant keys should be stored under their prefix")
+	}
+}
+
+func TestTenantRequired(t *testing.T) {
+	registry := newRegistry(t, Config{})
+
+	if _, err := registry.Router().Get(context.Background(), "key"); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrNoTenant)
+	}
+	if _, err := registry.Source("../alice"); !errors.Is(err, ErrInvalidID) {
+		t.Fatalf("Source() error = %v, want %v", err, ErrInvalidID)
+	}
+}
+
+func TestTenantQuotas(t *testing.T) {
+	registry := newRegistry(t, Config{
+		Quota:   Quota{MaxEntries: 2},
+		Quotas:  map[string]Quota{"limited": {OpsPerSecond: 0.001, Burst: 2}},
+		Layered: datasource.LayeredConfig{Mode: datasource.WriteThrough},
+	})
+	ctx := context.Background()
+
+	source, err := registry.Source("small")
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+	source.Set(ctx, "a", 1, time.Minute)
+	source.Set(ctx, "b", 2, time.Minute)
+	if err := source.Set(ctx, "a", 3, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v, replacing a key should stay within the quota", err)
+	}
+	if err := source.Set(ctx, "c", 4, time.Minute); !errors.Is(err, pkg.ErrQuotaExceeded) {
+		t.Fatalf("Set() error = %v, want %v", err, pkg.ErrQuotaExceeded)
+	}
+
+	limited, err := registry.Source("limited")
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+	limited.Set(ctx, "a", 1, time.Minute)
+	limited.Get(ctx, "a")
+	if _, err := limited.Get(ctx, "a"); !errors.Is(err, datasource.ErrRateLimited) {
+		t.Fatalf("Get() error = %v, want %v", err, datasource.ErrRateLimited)
+	}
+	if _, err := source.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get() error = %v, other tenants should not be limited", err)
+	}
+}
+
+func TestTenantWithoutQuotaKeepsCacheLimits(t *testing.T) {
+	registry := newRegistry(t, Config{})
+	registry.cache.Cache().SetQuota(pkg.Quota{MaxEntries: 1})
+	ctx := context.Background()
+
+	source, err := registry.Source("free")
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+	if err := source.Set(ctx, "a", 1, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := source.Set(ctx, "b", 2, time.Minute); !errors.Is(err, pkg.ErrQuotaExceeded) {
+		t.Fatalf("Set() error = %v, want the limits of the whole cache to apply", err)
+	}
+}
+
+func TestRateLimitedTenantWithFullCache(t *testing.T) {
+	registry := newRegistry(t, Config{Quota: Quota{OpsPerSecond: 1000, Burst: 10}})
+	registry.cache.Cache().SetQuota(pkg.Quota{MaxEntries: 1})
+	ctx := context.Background()
+
+	source, err := registry.Source("busy")
+	if err != nil {
+		t.Fatalf("Source() error = %v", err)
+	}
+	if err := source.Set(ctx, "a", 1, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	// The cache is full, the limiter state must not need room in it.
+	if got, err := source.Get(ctx, "a"); err != nil || got != float64(1) {
+		t.Fatalf("Get() = %v, %v, want the stored value", got, err)
+	}
+	if entries, _, _ := registry.cache.Cache().Usage(ctx, pkg.InternalPrefix); entries != 0 {
+		t.Fatalf("the cache holds %d limiter entries, want them kept in memory", entries)
+	}
+}
@@ -0,0 +1,199 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	bloomPrefix           = "bf:"
+	defaultBloomCapacity  = 1000
+	defaultBloomErrorRate = 0.01
+)
+
+type bloomFilter struct {
+	bits []byte
+	m    uint64
+	k    uint64
+}
+
+func newBloomFilter(capacity int, errorRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2)))
+	if m < 8 {
+		m = 8
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{
+		bits: make([]byte, (m+7)/8),
+		m:    m,
+		k:    k,
+	}
+}
+
+// add sets the bits of item and reports whether any of them was unset before,
+// i.e. whether the item was definitely not in the filter.
+func (f *bloomFilter) add(item string) bool {
+	added := false
+	for _, i := range f.indexes(item) {
+		if f.bits[i/8]&(1<<(i%8)) == 0 {
+			f.bits[i/8] |= 1 << (i % 8)
+			added = true
+		}
+	}
+	return added
+}
+
+func (f *bloomFilter) exists(item string) bool {
+	for _, i := range f.indexes(item) {
+		if f.bits[i/8]&(1<<(i%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) indexes(item string) []uint64 {
+	h1 := fnv.New64a()
+	h1.Write([]byte(item))
+	h2 := fnv.New64()
+	h2.Write([]byte(item))
+
+	a, b := h1.Sum64(), h2.Sum64()|1
+	indexes := make([]uint64, f.k)
+	for i := uint64(0); i < f.k; i++ {
+		indexes[i] = (a + i*b) % f.m
+	}
+	return indexes
+}
+
+func (f *bloomFilter) encode() string {
+	return bloomPrefix + strconv.FormatUint(f.m, 10) + ":" + strconv.FormatUint(f.k, 10) + ":" +
+		base64.StdEncoding.EncodeToString(f.bits)
+}
+
+func decodeBloomFilter(value string) (*bloomFilter, error) {
+	if !strings.HasPrefix(value, bloomPrefix) {
+		return nil, ErrWrongType
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, bloomPrefix), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid bloom filter encoding")
+	}
+	m, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bloom filter size: %w", err)
+	}
+	k, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bloom filter hash count: %w", err)
+	}
+	bits, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid bloom filter bits: %w", err)
+	}
+	if uint64(len(bits)) != (m+7)/8 || k == 0 {
+		return nil, errors.New("invalid bloom filter encoding")
+	}
+
+	return &bloomFilter{bits: bits, m: m, k: k}, nil
+}
+
+// BFReserve creates an empty bloom filter sized for capacity items with the given false positive rate.
+func (c *Cache) BFReserve(ctx context.Context, key string, errorRate float64, capacity int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if errorRate <= 0 || errorRate >= 1 {
+		return errors.New("error rate must be between 0 and 1")
+	}
+	if capacity <= 0 {
+		return errors.New("capacity must be positive")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.lookup(key); found {
+		return fmt.Errorf("key %s already exists", key)
+	}
+
+	if err := c.put(key, CacheItem{Value: newBloomFilter(capacity, errorRate).encode()}); err != nil {
+		return err
+	}
+	c.changed(key)
+	return c.saveToFile()
+}
+
+// BFAdd adds item to the bloom filter stored under key, creating a default one if needed.
+// It reports whether the item was added for the first time.
+func (c *Cache) BFAdd(ctx context.Context, key string, item string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	filter := newBloomFilter(defaultBloomCapacity, defaultBloomErrorRate)
+	current, found := c.lookup(key)
+	c.record(key, current.Value)
+	if found {
+		var err error
+		if filter, err = decodeBloomFilter(current.Value); err != nil {
+			return false, err
+		}
+	}
+
+	if !filter.add(item) {
+		return false, nil
+	}
+
+	if err := c.put(key, CacheItem{Value: filter.encode(), Expiration: current.Expiration}); err != nil {
+		return false, err
+	}
+	c.changed(key)
+	return true, c.saveToFile()
+}
+
+// BFExists reports whether item may have been added to the bloom filter stored under key.
+// A false result is definite.
+func (c *Cache) BFExists(ctx context.Context, key string, item string) (bool, error) {
+	exists, _, err := c.BFLookup(ctx, key, item)
+	return exists, err
+}
+
+// BFLookup is BFExists telling apart a missing filter: found is false when no filter is
+// stored under key, and nothing is known about item then.
+func (c *Cache) BFLookup(ctx context.Context, key string, item string) (exists, found bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, found := c.lookup(key)
+	c.record(key, current.Value)
+	if !found {
+		return false, false, nil
+	}
+
+	filter, err := decodeBloomFilter(current.Value)
+	if err != nil {
+		return false, true, err
+	}
+
+	return filter.exists(item), true, nil
+}
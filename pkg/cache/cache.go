@@ -3,65 +3,384 @@ package pkg
 import (
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+var (
+	ErrWrongType     = errors.New("operation against a key holding the wrong kind of value")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
 
+// InternalPrefix starts the keys holding the state of rate limiters and bloom filters
+// guarding other components. Quotas over all keys leave them out, so a full cache does
+// not stop those components from working; a quota on the prefix itself still limits them.
+const InternalPrefix = "internal:"
 
 type CacheItem struct {
-	Value      string
+	Value string
+	// Expiration is a Unix timestamp, zero means the item never expires.
 	Expiration int64
 }
 
+func (i CacheItem) expired(now time.Time) bool {
+	return i.Expiration != 0 && now.Unix() > i.Expiration
+}
+
+// expiresAt returns the Expiration of an item stored at now for expiration. A zero
+// expiration keeps the item forever, a negative one stores it already expired.
+func expiresAt(now time.Time, expiration time.Duration) int64 {
+	if expiration == 0 {
+		return 0
+	}
+	return now.Add(expiration).Unix()
+}
+
 type Cache struct {
-	items map[string]CacheItem
-	mu    sync.RWMutex
-	file  string
+	items       map[string]CacheItem
+	mu          sync.RWMutex
+	file        string
+	tracker     *Tracker
+	staleGrace  time.Duration
+	subscribers []func(key string)
+	quotas      []Quota
+	logger      *slog.Logger
+}
+
+// Quota limits the live items stored under keys starting with Prefix, zero limits
+// are not enforced.
+type Quota struct {
+	Prefix     string
+	MaxEntries int
+	// MaxBytes limits the total length of the keys and values.
+	MaxBytes int64
 }
 
-func NewCache(file string) *Cache {
+type Option func(*Cache)
+
+// WithLogger sets the logger of the cache, slog.Default() by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Cache) {
+		c.logger = logger
+	}
+}
+
+// NewCache returns a cache persisted to file and loaded from it, or kept in memory only
+// when file is empty. A file that cannot be loaded is logged rather than failing the cache.
+func NewCache(file string, opts ...Option) *Cache {
 	cache := &Cache{
-		items: make(map[string]CacheItem),
-		file:  file,
+		items:  make(map[string]CacheItem),
+		file:   file,
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(cache)
 	}
+
 	if err := cache.loadFromFile(); err != nil {
-		fmt.Printf("Error loading cache from file: %v\n", err)
+		cache.logger.Error("failed to load cache file", "file", file, "error", err)
+	} else {
+		cache.logger.Debug("cache loaded", "file", file, "items", len(cache.items))
 	}
 	return cache
 }
 
 func (c *Cache) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.items[key] = CacheItem{
+	item := CacheItem{
 		Value:      value,
-		Expiration: time.Now().Add(expiration).Unix(),
+		Expiration: expiresAt(time.Now(), expiration),
+	}
+	if err := c.put(key, item); err != nil {
+		return err
+	}
+	c.record(key, value)
+	c.changed(key)
+
+	return c.saveToFile()
+}
+
+type Entry struct {
+	Key        string
+	Value      string
+	Expiration time.Duration
+}
+
+// SetMany stores all entries and persists them with a single write. When an entry
+// would exceed a quota the ones before it are kept.
+func (c *Cache) SetMany(ctx context.Context, entries []Entry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, entry := range entries {
+		item := CacheItem{
+			Value:      entry.Value,
+			Expiration: expiresAt(now, entry.Expiration),
+		}
+		if err := c.put(entry.Key, item); err != nil {
+			return errors.Join(err, c.saveToFile())
+		}
+		c.record(entry.Key, entry.Value)
+		c.changed(entry.Key)
 	}
 
 	return c.saveToFile()
 }
 
 func (c *Cache) Get(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, found := c.lookup(key)
+	c.record(key, item.Value)
+	if !found {
+		return "", false, nil
+	}
+
+	return item.Value, true, nil
+}
+
+// GetStale works like Get but also returns values that expired less than the
+// KeepStale grace period ago.
+func (c *Cache) GetStale(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.lookup(key)
 	item, found := c.items[key]
-	if !found || time.Now().Unix() > item.Expiration {
-		if found {
-			delete(c.items, key)
-		}
+	c.record(key, item.Value)
+	if !found {
 		return "", false, nil
 	}
 
 	return item.Value, true, nil
 }
 
+// Delete removes key and reports whether it held a live value.
+func (c *Cache) Delete(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, found := c.lookup(key)
+	if _, kept := c.items[key]; !kept {
+		return false, nil
+	}
+
+	delete(c.items, key)
+	c.changed(key)
+	return found, c.saveToFile()
+}
+
+// Update atomically replaces the value stored under key with the one returned by fn.
+// fn receives the current value and whether it was found; returning an error leaves the item untouched.
+func (c *Cache) Update(ctx context.Context, key string, fn func(value string, found bool) (string, time.Duration, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, found := c.lookup(key)
+
+	newValue, expiration, err := fn(item.Value, found)
+	if err != nil {
+		return err
+	}
+
+	item = CacheItem{
+		Value:      newValue,
+		Expiration: expiresAt(time.Now(), expiration),
+	}
+	if err := c.put(key, item); err != nil {
+		return err
+	}
+	c.record(key, newValue)
+	c.changed(key)
+
+	return c.saveToFile()
+}
+
+// EnableTracking starts sampling accesses to report hot and large keys.
+func (c *Cache) EnableTracking(cfg TrackerConfig) *Tracker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tracker = NewTracker(cfg)
+	return c.tracker
+}
+
+// KeepStale keeps expired items for grace instead of dropping them right away, so
+// GetStale can serve them while the source of fresh values is unavailable.
+func (c *Cache) KeepStale(grace time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.staleGrace = grace
+}
+
+// Subscribe registers fn to be called with the key of every item that is set, updated
+// or deleted. fn runs with the cache locked, so it must be quick and must not call back
+// into the cache.
+func (c *Cache) Subscribe(fn func(key string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.subscribers = append(c.subscribers, fn)
+}
+
+// SetQuota limits the keys starting with quota.Prefix, replacing the previous quota
+// of the prefix. Writes exceeding it fail with ErrQuotaExceeded; a key matching
+// several quotas must stay within all of them.
+func (c *Cache) SetQuota(quota Quota) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.quotas {
+		if c.quotas[i].Prefix == quota.Prefix {
+			c.quotas[i] = quota
+			return
+		}
+	}
+	c.quotas = append(c.quotas, quota)
+}
+
+// Usage returns the number of live items stored under keys starting with prefix and
+// the total length of their keys and values, as counted by a quota on prefix.
+func (c *Cache) Usage(ctx context.Context, prefix string) (int, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries, bytes := c.usage(prefix, time.Now())
+	return entries, bytes, nil
+}
+
+// Tracker returns the access tracker, or nil if tracking is not enabled.
+func (c *Cache) Tracker() *Tracker {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.tracker
+}
+
+// record must be called with c.mu held.
+func (c *Cache) record(key, value string) {
+	if c.tracker != nil {
+		c.tracker.Record(key, len(value))
+	}
+}
+
+// put stores item under key unless that takes a quota of key over its limits.
+// The caller must hold c.mu.
+func (c *Cache) put(key string, item CacheItem) error {
+	now := time.Now()
+	for _, quota := range c.quotas {
+		if !counts(quota.Prefix, key) || (quota.MaxEntries <= 0 && quota.MaxBytes <= 0) {
+			continue
+		}
+
+		entries, bytes := c.usage(quota.Prefix, now)
+		if current, found := c.items[key]; found && !current.expired(now) {
+			entries--
+			bytes -= int64(len(key) + len(current.Value))
+		}
+		entries++
+		bytes += int64(len(key) + len(item.Value))
+
+		if (quota.MaxEntries > 0 && entries > quota.MaxEntries) || (quota.MaxBytes > 0 && bytes > quota.MaxBytes) {
+			return fmt.Errorf("%w for %q", ErrQuotaExceeded, quota.Prefix)
+		}
+	}
+
+	c.items[key] = item
+	return nil
+}
+
+// usage must be called with c.mu held.
+func (c *Cache) usage(prefix string, now time.Time) (int, int64) {
+	var (
+		entries int
+		bytes   int64
+	)
+	for key, item := range c.items {
+		if counts(prefix, key) && !item.expired(now) {
+			entries++
+			bytes += int64(len(key) + len(item.Value))
+		}
+	}
+	return entries, bytes
+}
+
+// counts reports whether key counts against a quota on prefix.
+func counts(prefix, key string) bool {
+	return strings.HasPrefix(key, prefix) && (prefix != "" || !strings.HasPrefix(key, InternalPrefix))
+}
+
+// changed must be called with c.mu held.
+func (c *Cache) changed(key string) {
+	for _, fn := range c.subscribers {
+		fn(key)
+	}
+}
+
+// lookup returns the live item stored under key. Expired items are dropped once
+// they are past the stale grace period, notifying the subscribers. The caller must
+// hold c.mu.
+func (c *Cache) lookup(key string) (CacheItem, bool) {
+	item, found := c.items[key]
+	if !found {
+		return CacheItem{}, false
+	}
+
+	now := time.Now()
+	if item.expired(now) {
+		if item.expired(now.Add(-c.staleGrace)) {
+			delete(c.items, key)
+			c.changed(key)
+		}
+		return CacheItem{}, false
+	}
+	return item, true
+}
+
 func (c *Cache) saveToFile() error {
+	if c.file == "" {
+		return nil
+	}
+
 	file, err := os.Create(c.file)
 	if err != nil {
 		return err
@@ -81,6 +400,10 @@ func (c *Cache) saveToFile() error {
 }
 
 func (c *Cache) loadFromFile() error {
+	if c.file == "" {
+		return nil
+	}
+
 	file, err := os.Open(c.file)
 	if err != nil {
 		if os.IsNotExist(err) {
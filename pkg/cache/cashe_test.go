@@ -2,23 +2,22 @@ package pkg
 
 import (
 	"context"
-	"own-database-cache/internal/config"
+	"errors"
 	"os"
+	"own-database-cache/internal/config"
 	"strconv"
 	"sync"
 	"testing"
 	"time"
 )
 
-const configPath = "../../config.json"
-
 func TestCacheSetAndGet(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	cacheDir := config.PathConfig.TestCacheFilePath
+	cacheDir := config.PathConfig.CacheFilePath
 	file := cacheDir + "test_cache.csv"
 	defer os.Remove(file)
 
@@ -47,12 +46,12 @@ func TestCacheSetAndGet(t *testing.T) {
 }
 
 func TestCacheExpiration(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	cacheDir := config.PathConfig.TestCacheFilePath
+	cacheDir := config.PathConfig.CacheFilePath
 
 	file := cacheDir + "test_cache.csv"
 	defer os.Remove(file)
@@ -79,12 +78,12 @@ func TestCacheExpiration(t *testing.T) {
 }
 
 func TestCacheConcurrency(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	cacheDir := config.PathConfig.TestCacheFilePath
+	cacheDir := config.PathConfig.CacheFilePath
 
 	file := cacheDir + "test_cache.csv"
 	defer os.Remove(file)
@@ -118,12 +117,12 @@ func TestCacheConcurrency(t *testing.T) {
 }
 
 func TestCachePersistence(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	cacheDir := config.PathConfig.TestCacheFilePath
+	cacheDir := config.PathConfig.CacheFilePath
 
 	file := cacheDir + "test_cache_persistence.csv"
 	defer os.Remove(file)
@@ -168,12 +167,12 @@ func TestCacheFileErrorHandling(t *testing.T) {
 }
 
 func TestCacheAutomaticExpiration(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	cacheDir := config.PathConfig.TestCacheFilePath
+	cacheDir := config.PathConfig.CacheFilePath
 
 	file := cacheDir + "test_cache_auto_expiration.csv"
 	defer os.Remove(file)
@@ -194,12 +193,12 @@ func TestCacheAutomaticExpiration(t *testing.T) {
 }
 
 func TestCacheConcurrentWriteAndDelete(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	cacheDir := config.PathConfig.TestCacheFilePath
+	cacheDir := config.PathConfig.CacheFilePath
 
 	file := cacheDir + "test_cache_concurrent_write_delete.csv"
 	defer os.Remove(file)
@@ -229,12 +228,12 @@ func TestCacheConcurrentWriteAndDelete(t *testing.T) {
 }
 
 func TestCacheDataValidation(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	cacheDir := config.PathConfig.TestCacheFilePath
+	cacheDir := config.PathConfig.CacheFilePath
 
 	file := cacheDir + "test_cache_data_validation.csv"
 	defer os.Remove(file)
@@ -257,3 +256,284 @@ func TestCacheDataValidation(t *testing.T) {
 		t.Fatal("Expected error when loading invalid data, but got nil")
 	}
 }
+
+func TestCacheUpdate(t *testing.T) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	cacheDir := config.PathConfig.CacheFilePath
+
+	file := cacheDir + "test_cache_update.csv"
+	defer os.Remove(file)
+
+	cache := NewCache(file)
+	ctx := context.Background()
+	key := "counter"
+
+	var wg sync.WaitGroup
+	concurrencyLevel := 50
+
+	for i := 0; i < concurrencyLevel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := cache.Update(ctx, key, func(value string, found bool) (string, time.Duration, error) {
+				n := 0
+				if found {
+					n, _ = strconv.Atoi(value)
+				}
+				return strconv.Itoa(n + 1), 5 * time.Second, nil
+			})
+			if err != nil {
+				t.Errorf("Update() error = %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	gotValue, found, err := cache.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !found || gotValue != strconv.Itoa(concurrencyLevel) {
+		t.Fatalf("Get() = %v, want %v", gotValue, concurrencyLevel)
+	}
+}
+
+func TestCacheDelete(t *testing.T) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	file := config.PathConfig.CacheFilePath + "test_cache_delete.csv"
+	defer os.Remove(file)
+
+	cache := NewCache(file)
+	ctx := context.Background()
+
+	cache.Set(ctx, "deleteKey", "deleteValue", 5*time.Second)
+
+	deleted, err := cache.Delete(ctx, "deleteKey")
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if !deleted {
+		t.Fatal("Delete() should report the existing key as deleted")
+	}
+
+	if _, found, _ := NewCache(file).Get(ctx, "deleteKey"); found {
+		t.Fatal("Get() found the key after Delete() and reload")
+	}
+
+	deleted, err = cache.Delete(ctx, "deleteKey")
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if deleted {
+		t.Fatal("Delete() should not report a missing key as deleted")
+	}
+}
+
+func TestCacheGetStale(t *testing.T) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	file := config.PathConfig.CacheFilePath + "test_cache_stale.csv"
+	defer os.Remove(file)
+
+	cache := NewCache(file)
+	ctx := context.Background()
+
+	cache.Set(ctx, "staleKey", "staleValue", -2*time.Second)
+	if _, found, _ := cache.GetStale(ctx, "staleKey"); found {
+		t.Fatal("GetStale() should not return expired values without a grace period")
+	}
+
+	cache.KeepStale(time.Minute)
+	cache.Set(ctx, "staleKey", "staleValue", -2*time.Second)
+
+	if _, found, _ := cache.Get(ctx, "staleKey"); found {
+		t.Fatal("Get() should not return expired values")
+	}
+	value, found, err := cache.GetStale(ctx, "staleKey")
+	if err != nil || !found || value != "staleValue" {
+		t.Fatalf("GetStale() = %q, %v, %v, want the expired value", value, found, err)
+	}
+
+	if deleted, _ := cache.Delete(ctx, "staleKey"); deleted {
+		t.Fatal("Delete() should not report an expired key as deleted")
+	}
+	if _, found, _ := cache.GetStale(ctx, "staleKey"); found {
+		t.Fatal("GetStale() found the key after Delete()")
+	}
+}
+
+func TestCacheContextCanceled(t *testing.T) {
+	cache := NewCache(t.TempDir() + "/cache.csv")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := cache.Set(ctx, "key", "value", time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Set() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := cache.BFAdd(ctx, "filter", "item"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("BFAdd() error = %v, want %v", err, context.Canceled)
+	}
+	if _, found, _ := cache.Get(context.Background(), "key"); found {
+		t.Fatal("Set() with a canceled context should not store the value")
+	}
+}
+
+func TestCacheSubscribe(t *testing.T) {
+	cache := NewCache(t.TempDir() + "/cache.csv")
+	ctx := context.Background()
+
+	var changed []string
+	cache.Subscribe(func(key string) { changed = append(changed, key) })
+
+	cache.Set(ctx, "a", "1", time.Minute)
+	cache.SetMany(ctx, []Entry{{Key: "b", Value: "2", Expiration: time.Minute}})
+	cache.Update(ctx, "a", func(value string, found bool) (string, time.Duration, error) {
+		return value + "1", time.Minute, nil
+	})
+	cache.Delete(ctx, "b")
+	cache.Delete(ctx, "missing")
+	cache.Get(ctx, "a")
+
+	// An expired item dropped while looking it up is reported as well.
+	cache.Set(ctx, "c", "3", -2*time.Second)
+	cache.Delete(ctx, "c")
+
+	cache.BFReserve(ctx, "bf", 0.01, 100)
+	cache.BFAdd(ctx, "bf", "x")
+	cache.PFAdd(ctx, "hll", "x")
+
+	want := []string{"a", "b", "a", "b", "c", "c", "bf", "bf", "hll"}
+	if len(changed) != len(want) {
+		t.Fatalf("changed = %v, want %v", changed, want)
+	}
+	for i := range want {
+		if changed[i] != want[i] {
+			t.Fatalf("changed = %v, want %v", changed, want)
+		}
+	}
+}
+
+func TestCacheQuota(t *testing.T) {
+	cache := NewCache(t.TempDir() + "/cache.csv")
+	ctx := context.Background()
+
+	cache.SetQuota(Quota{Prefix: "a:", MaxEntries: 2})
+	cache.SetQuota(Quota{Prefix: "a:big:", MaxBytes: 10})
+
+	cache.Set(ctx, "a:1", "x", time.Minute)
+	cache.Set(ctx, "a:2", "x", time.Minute)
+	if err := cache.Set(ctx, "a:3", "x", time.Minute); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Set() error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if err := cache.Set(ctx, "a:2", "y", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v, replacing a key should stay within the quota", err)
+	}
+	if err := cache.Set(ctx, "b:1", "x", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v for a key without quota", err)
+	}
+
+	if err := cache.Set(ctx, "a:big:1", "12345", time.Minute); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Set() error = %v, want the nested quota to apply", err)
+	}
+
+	err := cache.SetMany(ctx, []Entry{{Key: "a:2", Value: "z", Expiration: time.Minute}, {Key: "a:4", Value: "z", Expiration: time.Minute}})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("SetMany() error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if value, _, _ := cache.Get(ctx, "a:2"); value != "z" {
+		t.Fatalf("Get() = %q, entries before the failing one should be stored", value)
+	}
+
+	entries, bytes, err := cache.Usage(ctx, "a:")
+	if err != nil || entries != 2 || bytes != 8 {
+		t.Fatalf("Usage() = %d, %d, %v, want 2, 8", entries, bytes, err)
+	}
+}
+
+func TestCacheNestedQuotas(t *testing.T) {
+	cache := NewCache(t.TempDir() + "/cache.csv")
+	ctx := context.Background()
+
+	// A prefix without limits does not lift the quota over all keys.
+	cache.SetQuota(Quota{MaxEntries: 2})
+	cache.SetQuota(Quota{Prefix: "tenant:a:"})
+
+	cache.Set(ctx, "tenant:a:1", "x", time.Minute)
+	if err := cache.Set(ctx, "tenant:a:2", "x", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := cache.Set(ctx, "tenant:a:3", "x", time.Minute); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Set() error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if _, err := cache.BFAdd(ctx, "bf", "x"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("BFAdd() error = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if _, err := cache.PFAdd(ctx, "hll", "x"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("PFAdd() error = %v, want %v", err, ErrQuotaExceeded)
+	}
+
+	entries, _, err := cache.Usage(ctx, "")
+	if err != nil || entries != 2 {
+		t.Fatalf("Usage() = %d, %v, want 2", entries, err)
+	}
+}
+
+func TestCacheQuotaLeavesOutInternalKeys(t *testing.T) {
+	cache := NewCache(t.TempDir() + "/cache.csv")
+	ctx := context.Background()
+
+	cache.SetQuota(Quota{MaxEntries: 1})
+	cache.Set(ctx, "key", "x", time.Minute)
+	if _, err := cache.BFAdd(ctx, InternalPrefix+"bf", "x"); err != nil {
+		t.Fatalf("BFAdd() error = %v, internal keys should not count against the quota over all keys", err)
+	}
+	if entries, _, _ := cache.Usage(ctx, ""); entries != 1 {
+		t.Fatalf("Usage() = %d, want the internal key left out", entries)
+	}
+
+	cache.SetQuota(Quota{Prefix: InternalPrefix, MaxEntries: 1})
+	if _, err := cache.PFAdd(ctx, InternalPrefix+"hll", "x"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("PFAdd() error = %v, want the quota of the internal prefix to apply", err)
+	}
+}
+
+func TestCacheInMemory(t *testing.T) {
+	cache := NewCache("")
+	ctx := context.Background()
+
+	if err := cache.Set(ctx, "key", "value", time.Minute); err != nil {
+		t.Fatalf("Set() error = %v, a cache without a file should not save one", err)
+	}
+	if value, found, _ := cache.Get(ctx, "key"); !found || value != "value" {
+		t.Fatalf("Get() = %q, %v", value, found)
+	}
+}
+
+func TestCacheZeroExpirationNeverExpires(t *testing.T) {
+	cache := NewCache("")
+	ctx := context.Background()
+
+	cache.Set(ctx, "set", "value", 0)
+	cache.SetMany(ctx, []Entry{{Key: "many", Value: "value"}})
+	cache.Update(ctx, "update", func(string, bool) (string, time.Duration, error) {
+		return "value", 0, nil
+	})
+
+	for _, key := range []string{"set", "many", "update"} {
+		if item := cache.items[key]; item.Expiration != 0 {
+			t.Fatalf("%s: Expiration = %v, want 0 for a key that never expires", key, item.Expiration)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+const (
+	hllPrefix    = "hll:"
+	hllPrecision = 12
+	hllRegisters = 1 << hllPrecision
+)
+
+// hyperLogLog estimates cardinality with a standard error of about 1.6%.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, hllRegisters)}
+}
+
+func (h *hyperLogLog) add(item string) bool {
+	hash := hllHash(item)
+	index := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	if rank <= h.registers[index] {
+		return false
+	}
+	h.registers[index] = rank
+	return true
+}
+
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+func (h *hyperLogLog) count() uint64 {
+	sum := 0.0
+	zeros := 0
+	for _, rank := range h.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(hllRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+func (h *hyperLogLog) encode() string {
+	return hllPrefix + base64.StdEncoding.EncodeToString(h.registers)
+}
+
+func decodeHyperLogLog(value string) (*hyperLogLog, error) {
+	if !strings.HasPrefix(value, hllPrefix) {
+		return nil, ErrWrongType
+	}
+
+	registers, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, hllPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hyperloglog registers: %w", err)
+	}
+	if len(registers) != hllRegisters {
+		return nil, errors.New("invalid hyperloglog encoding")
+	}
+
+	return &hyperLogLog{registers: registers}, nil
+}
+
+// hllHash spreads FNV-1a over all 64 bits, which HyperLogLog relies on.
+func hllHash(item string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// PFAdd adds items to the HyperLogLog stored under key, creating it if needed.
+// It reports whether the estimated cardinality may have changed.
+func (c *Cache) PFAdd(ctx context.Context, key string, items ...string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hll := newHyperLogLog()
+	current, found := c.lookup(key)
+	c.record(key, current.Value)
+	if found {
+		var err error
+		if hll, err = decodeHyperLogLog(current.Value); err != nil {
+			return false, err
+		}
+	}
+
+	changed := !found
+	for _, item := range items {
+		if hll.add(item) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if err := c.put(key, CacheItem{Value: hll.encode(), Expiration: current.Expiration}); err != nil {
+		return false, err
+	}
+	c.changed(key)
+	return true, c.saveToFile()
+}
+
+// PFCount returns the estimated number of unique items added to the union of keys.
+func (c *Cache) PFCount(ctx context.Context, keys ...string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	union := newHyperLogLog()
+	for _, key := range keys {
+		current, found := c.lookup(key)
+		c.record(key, current.Value)
+		if !found {
+			continue
+		}
+
+		hll, err := decodeHyperLogLog(current.Value)
+		if err != nil {
+			return 0, err
+		}
+		union.merge(hll)
+	}
+
+	return union.count(), nil
+}
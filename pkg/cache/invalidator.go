@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"own-database-cache/pkg/database"
+)
+
+type InvalidatorConfig struct {
+	Table     string
+	KeyColumn string
+	// ValueColumn, when set, makes the invalidator store the new values of inserted and
+	// updated rows instead of dropping their keys.
+	ValueColumn string
+	// ExpiresAtColumn, when set, holds the unix time rows expire at, 0 meaning never.
+	// Refreshed keys expire with their row at the latest, and keys of expired rows are dropped.
+	ExpiresAtColumn string
+	Expiration      time.Duration
+	// TTL, when set, chooses the expiration of refreshed keys instead of Expiration.
+	TTL func(key string) time.Duration
+	// Encode converts a database value into the form stored in the cache, values are stored as is when nil.
+	Encode func(value string) (string, error)
+	// OnError is called when a change could not be applied to the cache.
+	OnError func(change database.Change, err error)
+}
+
+// Invalidator keeps the cache entries derived from a table in line with the rows
+// changed in the database, so they do not stay stale until they expire.
+type Invalidator struct {
+	cache *Cache
+	cfg   InvalidatorConfig
+}
+
+func NewInvalidator(cache *Cache, cfg InvalidatorConfig) *Invalidator {
+	if cfg.Encode == nil {
+		cfg.Encode = func(value string) (string, error) {
+			return value, nil
+		}
+	}
+	return &Invalidator{cache: cache, cfg: cfg}
+}
+
+// Subscribe applies every change committed to db from now on.
+func (i *Invalidator) Subscribe(db *database.Database) {
+	db.Subscribe(func(change database.Change) {
+		// The change is already committed, so it is applied even if the writer gave up.
+		if err := i.Apply(context.Background(), change); err != nil && i.cfg.OnError != nil {
+			i.cfg.OnError(change, err)
+		}
+	})
+}
+
+// Apply drops or refreshes the keys of the rows in change.
+func (i *Invalidator) Apply(ctx context.Context, change database.Change) error {
+	if change.Table != i.cfg.Table {
+		return nil
+	}
+
+	oldKeys, newKeys := change.Values(i.cfg.KeyColumn)
+	handled := make(map[string]bool)
+	if _, values := change.Values(i.cfg.ValueColumn); len(values) == len(newKeys) {
+		_, expires := change.Values(i.cfg.ExpiresAtColumn)
+		now := time.Now()
+		entries := make([]Entry, 0, len(values))
+		for n, value := range values {
+			key := newKeys[n]
+			expiration := i.expiration(key)
+			if len(expires) == len(values) {
+				var live bool
+				var err error
+				if expiration, live, err = rowExpiration(expiration, expires[n], now); err != nil {
+					return fmt.Errorf("invalid %s of key %s: %w", i.cfg.ExpiresAtColumn, key, err)
+				}
+				if !live {
+					// The row has already expired, its key is dropped below.
+					continue
+				}
+			}
+
+			value, err := i.cfg.Encode(value)
+			if err != nil {
+				return fmt.Errorf("failed to encode value of key %s: %w", key, err)
+			}
+			entries = append(entries, Entry{Key: key, Value: value, Expiration: expiration})
+			handled[key] = true
+		}
+		if len(entries) > 0 {
+			if err := i.cache.SetMany(ctx, entries); err != nil {
+				return fmt.Errorf("failed to refresh changed keys: %w", err)
+			}
+		}
+	}
+
+	for _, key := range append(oldKeys, newKeys...) {
+		if handled[key] {
+			continue
+		}
+		if _, err := i.cache.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to drop key %s: %w", key, err)
+		}
+		handled[key] = true
+	}
+
+	return nil
+}
+
+func (i *Invalidator) expiration(key string) time.Duration {
+	if i.cfg.TTL != nil {
+		return i.cfg.TTL(key)
+	}
+	return i.cfg.Expiration
+}
+
+// rowExpiration caps expiration to the lifetime left to a row expiring at the unix time
+// expiresAt, 0 meaning never, and reports whether the row is still live.
+func rowExpiration(expiration time.Duration, expiresAt string, now time.Time) (time.Duration, bool, error) {
+	unix, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	if unix <= 0 {
+		return expiration, true, nil
+	}
+	remaining := time.Unix(unix, 0).Sub(now)
+	if remaining <= 0 {
+		return 0, false, nil
+	}
+	if expiration <= 0 || remaining < expiration {
+		expiration = remaining
+	}
+	return expiration, true, nil
+}
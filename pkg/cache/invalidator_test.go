@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInvalidatorDropsChangedKeys(t *testing.T) {
+	cache, db := setupWarmer(t, "test_cache_invalidator_drop")
+	ctx := context.Background()
+
+	NewInvalidator(cache, InvalidatorConfig{Table: "users", KeyColumn: "id"}).Subscribe(db)
+	for _, key := range []string{"1", "2", "3"} {
+		cache.Set(ctx, key, "cached", time.Minute)
+	}
+
+	txn, _ := db.Begin(ctx)
+	db.Exec(ctx, txn, "UPDATE users SET name = 'Bobby' WHERE id = '2'")
+	db.Exec(ctx, txn, "DELETE FROM users WHERE id = '3'")
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if _, found, _ := cache.Get(ctx, "1"); !found {
+		t.Fatal("an unchanged row should stay cached")
+	}
+	for _, key := range []string{"2", "3"} {
+		if _, found, _ := cache.Get(ctx, key); found {
+			t.Fatalf("key %s of a changed row is still cached", key)
+		}
+	}
+}
+
+func TestInvalidatorRefreshesChangedKeys(t *testing.T) {
+	cache, db := setupWarmer(t, "test_cache_invalidator_refresh")
+	ctx := context.Background()
+
+	NewInvalidator(cache, InvalidatorConfig{
+		Table:       "users",
+		KeyColumn:   "id",
+		ValueColumn: "name",
+		Expiration:  time.Minute,
+	}).Subscribe(db)
+	cache.Set(ctx, "2", "Bob", time.Minute)
+
+	txn, _ := db.Begin(ctx)
+	db.Exec(ctx, txn, "UPDATE users SET id = '5', name = 'Bobby' WHERE id = '2'")
+	db.Exec(ctx, txn, "INSERT INTO users (id, name) VALUES ('4', 'Dave')")
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if _, found, _ := cache.Get(ctx, "2"); found {
+		t.Fatal("the old key of an updated row is still cached")
+	}
+	for key, want := range map[string]string{"5": "Bobby", "4": "Dave"} {
+		if value, found, _ := cache.Get(ctx, key); !found || value != want {
+			t.Fatalf("Get(%s) = %q, %v, want %q", key, value, found, want)
+		}
+	}
+}
+
+func TestInvalidatorRefreshesWithRowExpiry(t *testing.T) {
+	cache, db := setupWarmer(t, "test_cache_invalidator_expiry")
+	ctx := context.Background()
+
+	NewInvalidator(cache, InvalidatorConfig{
+		Table:           "sessions",
+		KeyColumn:       "id",
+		ValueColumn:     "name",
+		ExpiresAtColumn: "expiresAt",
+		Expiration:      time.Hour,
+	}).Subscribe(db)
+	cache.Set(ctx, "expired", "stale", time.Minute)
+
+	now := time.Now()
+	txn, _ := db.Begin(ctx)
+	db.Exec(ctx, txn, "CREATE TABLE sessions (id, name, expiresAt) WITH TYPES (string, string, int64)")
+	db.Exec(ctx, txn, "INSERT INTO sessions (id, name, expiresAt) VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+		"expired", "Alice", now.Add(-time.Minute).Unix(),
+		"short", "Bob", now.Add(time.Minute).Unix(),
+		"forever", "Carol", 0)
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if _, found, _ := cache.Get(ctx, "expired"); found {
+		t.Fatal("the key of an expired row is still cached")
+	}
+	for key, want := range map[string]time.Duration{"short": time.Minute, "forever": time.Hour} {
+		item, found := cache.items[key]
+		if !found {
+			t.Fatalf("key %s of a live row is not cached", key)
+		}
+		if ttl := time.Unix(item.Expiration, 0).Sub(now); ttl < want-2*time.Second || ttl > want+time.Second {
+			t.Fatalf("key %s expires in %v, want %v", key, ttl, want)
+		}
+	}
+}
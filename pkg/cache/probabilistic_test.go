@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"context"
+	"math"
+	"os"
+	"own-database-cache/internal/config"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter(t *testing.T) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	file := config.PathConfig.CacheFilePath + "test_cache_bloom.csv"
+	defer os.Remove(file)
+
+	cache := NewCache(file)
+	ctx := context.Background()
+	key := "written-keys"
+
+	if err := cache.BFReserve(ctx, key, 0.01, 500); err != nil {
+		t.Fatalf("BFReserve() error = %v", err)
+	}
+
+	for i := 0; i < 500; i++ {
+		added, err := cache.BFAdd(ctx, key, "user:"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("BFAdd() error = %v", err)
+		}
+		if i == 0 && !added {
+			t.Fatal("BFAdd() should report the first item as added")
+		}
+	}
+
+	added, err := cache.BFAdd(ctx, key, "user:0")
+	if err != nil {
+		t.Fatalf("BFAdd() error = %v", err)
+	}
+	if added {
+		t.Fatal("BFAdd() should not report an existing item as added")
+	}
+
+	reloaded := NewCache(file)
+	for i := 0; i < 500; i++ {
+		exists, err := reloaded.BFExists(ctx, key, "user:"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("BFExists() error = %v", err)
+		}
+		if !exists {
+			t.Fatalf("BFExists() = false for added item %d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 500; i < 5500; i++ {
+		exists, err := reloaded.BFExists(ctx, key, "user:"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("BFExists() error = %v", err)
+		}
+		if exists {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 5000; rate > 0.03 {
+		t.Fatalf("false positive rate = %v, want about 0.01", rate)
+	}
+
+	if exists, err := reloaded.BFExists(ctx, "missing", "user:0"); err != nil || exists {
+		t.Fatalf("BFExists() on missing key = %v, %v", exists, err)
+	}
+	if exists, found, err := reloaded.BFLookup(ctx, "missing", "user:0"); err != nil || exists || found {
+		t.Fatalf("BFLookup() on missing key = %v, %v, %v", exists, found, err)
+	}
+	if exists, found, err := reloaded.BFLookup(ctx, key, "user:0"); err != nil || !exists || !found {
+		t.Fatalf("BFLookup() = %v, %v, %v for an added item", exists, found, err)
+	}
+
+	cache.Set(ctx, "plain", "value", 5*time.Second)
+	if _, err := cache.BFAdd(ctx, "plain", "user:0"); err != ErrWrongType {
+		t.Fatalf("BFAdd() error = %v, want %v", err, ErrWrongType)
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	file := config.PathConfig.CacheFilePath + "test_cache_hyperloglog.csv"
+	defer os.Remove(file)
+
+	cache := NewCache(file)
+	ctx := context.Background()
+
+	var visitors []string
+	for i := 0; i < 10000; i++ {
+		visitors = append(visitors, "visitor:"+strconv.Itoa(i))
+	}
+
+	if _, err := cache.PFAdd(ctx, "monday", visitors[:6000]...); err != nil {
+		t.Fatalf("PFAdd() error = %v", err)
+	}
+	if _, err := cache.PFAdd(ctx, "tuesday", visitors[4000:]...); err != nil {
+		t.Fatalf("PFAdd() error = %v", err)
+	}
+
+	changed, err := cache.PFAdd(ctx, "monday", visitors[0])
+	if err != nil {
+		t.Fatalf("PFAdd() error = %v", err)
+	}
+	if changed {
+		t.Fatal("PFAdd() should not report a change for a repeated visitor")
+	}
+
+	reloaded := NewCache(file)
+	tests := []struct {
+		keys []string
+		want float64
+	}{
+		{[]string{"monday"}, 6000},
+		{[]string{"tuesday"}, 6000},
+		{[]string{"monday", "tuesday"}, 10000},
+		{[]string{"missing"}, 0},
+	}
+
+	for _, tt := range tests {
+		got, err := reloaded.PFCount(ctx, tt.keys...)
+		if err != nil {
+			t.Fatalf("PFCount() error = %v", err)
+		}
+		if math.Abs(float64(got)-tt.want) > tt.want*0.05 {
+			t.Fatalf("PFCount(%v) = %v, want about %v", tt.keys, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+type TrackerConfig struct {
+	// SampleRate is the fraction of accesses that are recorded, 1 records every access.
+	SampleRate float64
+	Window     time.Duration
+	// Buckets is the number of slices the window is split into; the oldest slice is
+	// dropped as the window slides.
+	Buckets int
+}
+
+type KeyStat struct {
+	Key string
+	// Hits is estimated from the sampled accesses.
+	Hits int64
+	Size int
+}
+
+type trackerBucket struct {
+	hits  map[string]int64
+	sizes map[string]int
+}
+
+// Tracker samples cache accesses to find the hottest and the largest keys over a sliding window.
+type Tracker struct {
+	mu       sync.Mutex
+	cfg      TrackerConfig
+	buckets  []trackerBucket
+	current  int
+	started  time.Time
+	interval time.Duration
+	now      func() time.Time
+	sample   func() float64
+}
+
+func NewTracker(cfg TrackerConfig) *Tracker {
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = 1
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.Buckets <= 0 {
+		cfg.Buckets = 6
+	}
+
+	t := &Tracker{
+		cfg:      cfg,
+		buckets:  make([]trackerBucket, cfg.Buckets),
+		interval: cfg.Window / time.Duration(cfg.Buckets),
+		now:      time.Now,
+		sample:   rand.Float64,
+	}
+	for i := range t.buckets {
+		t.buckets[i] = newTrackerBucket()
+	}
+	t.started = t.now()
+
+	return t
+}
+
+func newTrackerBucket() trackerBucket {
+	return trackerBucket{
+		hits:  make(map[string]int64),
+		sizes: make(map[string]int),
+	}
+}
+
+// Record registers an access to key holding a value of size bytes.
+func (t *Tracker) Record(key string, size int) {
+	if t.cfg.SampleRate < 1 && t.sample() >= t.cfg.SampleRate {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rotate()
+	bucket := t.buckets[t.current]
+	bucket.hits[key]++
+	if size > bucket.sizes[key] {
+		bucket.sizes[key] = size
+	}
+}
+
+// HotKeys returns up to n keys with the most accesses in the window.
+func (t *Tracker) HotKeys(n int) []KeyStat {
+	return t.top(n, func(a, b KeyStat) bool {
+		return a.Hits > b.Hits
+	})
+}
+
+// LargeKeys returns up to n keys with the largest values seen in the window.
+func (t *Tracker) LargeKeys(n int) []KeyStat {
+	return t.top(n, func(a, b KeyStat) bool {
+		return a.Size > b.Size
+	})
+}
+
+func (t *Tracker) top(n int, less func(a, b KeyStat) bool) []KeyStat {
+	t.mu.Lock()
+	stats := make(map[string]*KeyStat)
+	t.rotate()
+	for _, bucket := range t.buckets {
+		for key, hits := range bucket.hits {
+			stat, ok := stats[key]
+			if !ok {
+				stat = &KeyStat{Key: key}
+				stats[key] = stat
+			}
+			stat.Hits += hits
+			if size := bucket.sizes[key]; size > stat.Size {
+				stat.Size = size
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	result := make([]KeyStat, 0, len(stats))
+	for _, stat := range stats {
+		stat.Hits = int64(float64(stat.Hits)/t.cfg.SampleRate + 0.5)
+		result = append(result, *stat)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if less(result[i], result[j]) {
+			return true
+		}
+		if less(result[j], result[i]) {
+			return false
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	if n >= 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// rotate advances the window to the current time, clearing buckets that fell out of it.
+// The caller must hold t.mu.
+func (t *Tracker) rotate() {
+	elapsed := t.now().Sub(t.started)
+	if elapsed < t.interval {
+		return
+	}
+
+	steps := int(elapsed / t.interval)
+	if steps > len(t.buckets) {
+		steps = len(t.buckets)
+	}
+	for i := 0; i < steps; i++ {
+		t.current = (t.current + 1) % len(t.buckets)
+		t.buckets[t.current] = newTrackerBucket()
+	}
+	t.started = t.started.Add(elapsed.Truncate(t.interval))
+}
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"own-database-cache/internal/config"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrackerHotAndLargeKeys(t *testing.T) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	file := config.PathConfig.CacheFilePath + "test_cache_tracker.csv"
+	defer os.Remove(file)
+
+	cache := NewCache(file)
+	tracker := cache.EnableTracking(TrackerConfig{SampleRate: 1, Window: time.Minute})
+	ctx := context.Background()
+
+	cache.Set(ctx, "small", "v", time.Minute)
+	cache.Set(ctx, "large", strings.Repeat("v", 1024), time.Minute)
+	for i := 0; i < 10; i++ {
+		cache.Get(ctx, "small")
+	}
+	cache.Get(ctx, "missing")
+
+	hot := tracker.HotKeys(2)
+	wantHot := []KeyStat{
+		{Key: "small", Hits: 11, Size: 1},
+		{Key: "large", Hits: 1, Size: 1024},
+	}
+	if !reflect.DeepEqual(hot, wantHot) {
+		t.Fatalf("HotKeys() = %v, want %v", hot, wantHot)
+	}
+
+	large := tracker.LargeKeys(1)
+	if len(large) != 1 || large[0].Key != "large" {
+		t.Fatalf("LargeKeys() = %v, want the large key first", large)
+	}
+}
+
+func TestTrackerSlidingWindow(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(TrackerConfig{SampleRate: 1, Window: 3 * time.Second, Buckets: 3})
+	tracker.now = func() time.Time { return now }
+	tracker.started = now
+
+	tracker.Record("old", 10)
+	now = now.Add(2 * time.Second)
+	tracker.Record("new", 10)
+	tracker.Record("new", 10)
+
+	if hot := tracker.HotKeys(10); len(hot) != 2 {
+		t.Fatalf("HotKeys() = %v, want both keys inside the window", hot)
+	}
+
+	now = now.Add(time.Second)
+	hot := tracker.HotKeys(10)
+	if len(hot) != 1 || hot[0].Key != "new" || hot[0].Hits != 2 {
+		t.Fatalf("HotKeys() = %v, want only the recent key", hot)
+	}
+
+	now = now.Add(time.Hour)
+	if hot := tracker.HotKeys(10); len(hot) != 0 {
+		t.Fatalf("HotKeys() = %v, want an empty window", hot)
+	}
+}
+
+func TestTrackerSampling(t *testing.T) {
+	tracker := NewTracker(TrackerConfig{SampleRate: 0.5, Window: time.Minute})
+	sampled := false
+	tracker.sample = func() float64 {
+		sampled = !sampled
+		if sampled {
+			return 0
+		}
+		return 0.9
+	}
+
+	for i := 0; i < 100; i++ {
+		tracker.Record("key", 1)
+	}
+
+	hot := tracker.HotKeys(1)
+	if len(hot) != 1 || hot[0].Hits != 100 {
+		t.Fatalf("HotKeys() = %v, want 100 estimated hits", hot)
+	}
+}
@@ -0,0 +1,250 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"own-database-cache/pkg/database"
+)
+
+const warmBatchSize = 100
+
+type WarmerConfig struct {
+	// Concurrency limits the number of database queries running at once when warming separate keys.
+	Concurrency int
+	// ExpiresAtColumn, when set, holds the unix time rows expire at, 0 meaning never.
+	// Expired rows are skipped and the others are cached until their row expires at the latest.
+	ExpiresAtColumn string
+	Expiration      time.Duration
+	// TTL, when set, chooses the expiration of every key instead of Expiration.
+	TTL func(key string) time.Duration
+	// Encode converts a database value into the form stored in the cache, values are stored as is when nil.
+	Encode func(value string) (string, error)
+	// Progress is called after every stored batch with the number of processed and total rows.
+	Progress func(done, total int)
+}
+
+// Warmer preloads the cache from the database so a fresh process does not start cold.
+type Warmer struct {
+	cache *Cache
+	db    *database.Database
+	cfg   WarmerConfig
+}
+
+func NewWarmer(cache *Cache, db *database.Database, cfg WarmerConfig) *Warmer {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Encode == nil {
+		cfg.Encode = func(value string) (string, error) {
+			return value, nil
+		}
+	}
+	return &Warmer{
+		cache: cache,
+		db:    db,
+		cfg:   cfg,
+	}
+}
+
+// WarmTable loads every live row of table into the cache and returns the number of stored keys.
+func (w *Warmer) WarmTable(ctx context.Context, table, keyColumn, valueColumn string) (int, error) {
+	return w.WarmQuery(ctx, fmt.Sprintf("SELECT %s FROM %s", w.columns(ctx, table, keyColumn, valueColumn), table))
+}
+
+// WarmQuery runs a SELECT returning key and value columns, optionally followed by the
+// unix time the row expires at, and loads the live rows into the cache.
+func (w *Warmer) WarmQuery(ctx context.Context, query string) (int, error) {
+	rows, err := w.db.Query(ctx, query)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, database.ErrNoRecords) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query warm-up rows: %w", err)
+	}
+
+	rows = rows[1:]
+	stored := 0
+	batch := make([]Entry, 0, warmBatchSize)
+	for i, row := range rows {
+		if len(row) != 2 && len(row) != 3 {
+			return stored, errors.New("warm-up query must select a key, a value and optionally an expiry column")
+		}
+
+		var expiresAt string
+		if len(row) == 3 {
+			expiresAt = row[2]
+		}
+		entry, live, err := w.entry(row[0], row[1], expiresAt)
+		if err != nil {
+			return stored, err
+		}
+		if live {
+			if entry.Value, err = w.cfg.Encode(entry.Value); err != nil {
+				return stored, fmt.Errorf("failed to encode value of key %s: %w", row[0], err)
+			}
+			batch = append(batch, entry)
+		}
+
+		if len(batch) == warmBatchSize || i == len(rows)-1 {
+			if err := w.flush(ctx, batch, i+1, len(rows)); err != nil {
+				return stored, err
+			}
+			stored += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	return stored, nil
+}
+
+// WarmKeys looks up each of keys in table and loads the found ones into the cache.
+// Keys missing from the table or whose row has expired are skipped.
+func (w *Warmer) WarmKeys(ctx context.Context, table, keyColumn, valueColumn string, keys []string) (int, error) {
+	type result struct {
+		key, value, expiresAt string
+		found                 bool
+		err                   error
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", w.columns(ctx, table, keyColumn, valueColumn), table, keyColumn)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan string)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				row, err := w.db.QueryRow(ctx, query, key)
+				if errors.Is(err, fs.ErrNotExist) || errors.Is(err, database.ErrNoRows) || errors.Is(err, database.ErrNoRecords) {
+					results <- result{key: key}
+					continue
+				}
+				if err != nil {
+					results <- result{key: key, err: err}
+					continue
+				}
+
+				var expiresAt string
+				if len(row) == 3 {
+					expiresAt = row[2]
+				}
+				value, err := w.cfg.Encode(row[1])
+				results <- result{key: key, value: value, expiresAt: expiresAt, found: true, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, key := range keys {
+			select {
+			case jobs <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stored, done := 0, 0
+	var firstErr error
+	batch := make([]Entry, 0, warmBatchSize)
+	for res := range results {
+		done++
+		if firstErr != nil {
+			continue
+		}
+		if res.err != nil {
+			firstErr = fmt.Errorf("failed to warm key %s: %w", res.key, res.err)
+			cancel()
+			continue
+		}
+
+		if res.found {
+			entry, live, err := w.entry(res.key, res.value, res.expiresAt)
+			if err != nil {
+				firstErr = err
+				cancel()
+				continue
+			}
+			if live {
+				batch = append(batch, entry)
+			}
+		}
+		if len(batch) == warmBatchSize || done == len(keys) {
+			if err := w.flush(ctx, batch, done, len(keys)); err != nil {
+				firstErr = err
+				cancel()
+				continue
+			}
+			stored += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	if firstErr != nil {
+		return stored, firstErr
+	}
+	return stored, ctx.Err()
+}
+
+// columns returns the selected columns of table, with ExpiresAtColumn when table has it.
+func (w *Warmer) columns(ctx context.Context, table, keyColumn, valueColumn string) string {
+	selected := keyColumn + ", " + valueColumn
+	if w.cfg.ExpiresAtColumn == "" {
+		return selected
+	}
+	// A missing table is reported by the query itself.
+	columns, _, _ := w.db.Columns(ctx, table)
+	for _, column := range columns {
+		if column == w.cfg.ExpiresAtColumn {
+			return selected + ", " + column
+		}
+	}
+	return selected
+}
+
+// entry returns the cache entry of a row expiring at expiresAt, empty when it never
+// does, and whether the row is still live.
+func (w *Warmer) entry(key, value, expiresAt string) (Entry, bool, error) {
+	expiration := w.cfg.Expiration
+	if w.cfg.TTL != nil {
+		expiration = w.cfg.TTL(key)
+	}
+	if expiresAt == "" {
+		return Entry{Key: key, Value: value, Expiration: expiration}, true, nil
+	}
+
+	expiration, live, err := rowExpiration(expiration, expiresAt, time.Now())
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("invalid %s of key %s: %w", w.cfg.ExpiresAtColumn, key, err)
+	}
+	return Entry{Key: key, Value: value, Expiration: expiration}, live, nil
+}
+
+func (w *Warmer) flush(ctx context.Context, batch []Entry, done, total int) error {
+	if len(batch) > 0 {
+		if err := w.cache.SetMany(ctx, batch); err != nil {
+			return fmt.Errorf("failed to store warm-up batch: %w", err)
+		}
+	}
+	if w.cfg.Progress != nil {
+		w.cfg.Progress(done, total)
+	}
+	return nil
+}
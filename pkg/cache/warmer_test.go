@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"own-database-cache/internal/config"
+	"own-database-cache/pkg/database"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupWarmer(t *testing.T, name string) (*Cache, *database.Database) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	cacheFile := config.PathConfig.CacheFilePath + name + ".csv"
+	databaseDir := config.PathConfig.CacheFilePath + name + "/"
+	t.Cleanup(func() {
+		os.Remove(cacheFile)
+		os.RemoveAll(databaseDir)
+	})
+
+	db := database.NewDatabase(databaseDir)
+	ctx := context.Background()
+
+	txn, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	db.Exec(ctx, txn, "CREATE TABLE users (id, name) WITH TYPES (string, string)")
+	db.Exec(ctx, txn, "INSERT INTO users (id, name) VALUES ('1', 'Alice'), ('2', 'Bob'), ('3', 'Charlie')")
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+
+	return NewCache(cacheFile), db
+}
+
+func TestWarmerTable(t *testing.T) {
+	cache, db := setupWarmer(t, "test_cache_warmer_table")
+	ctx := context.Background()
+
+	var progress [][2]int
+	warmer := NewWarmer(cache, db, WarmerConfig{
+		Expiration: time.Minute,
+		Progress: func(done, total int) {
+			progress = append(progress, [2]int{done, total})
+		},
+	})
+
+	stored, err := warmer.WarmTable(ctx, "users", "id", "name")
+	if err != nil {
+		t.Fatalf("WarmTable() error = %v", err)
+	}
+	if stored != 3 {
+		t.Fatalf("WarmTable() = %v, want %v", stored, 3)
+	}
+	if len(progress) != 1 || progress[0] != [2]int{3, 3} {
+		t.Fatalf("progress = %v, want a single 3/3 report", progress)
+	}
+
+	for key, want := range map[string]string{"1": "Alice", "2": "Bob", "3": "Charlie"} {
+		got, found, err := cache.Get(ctx, key)
+		if err != nil || !found || got != want {
+			t.Fatalf("Get(%s) = %v, %v, %v, want %v", key, got, found, err, want)
+		}
+	}
+
+	if stored, err := warmer.WarmTable(ctx, "missing", "id", "name"); err != nil || stored != 0 {
+		t.Fatalf("WarmTable() on a missing table = %v, %v", stored, err)
+	}
+}
+
+func TestWarmerKeys(t *testing.T) {
+	cache, db := setupWarmer(t, "test_cache_warmer_keys")
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	done := 0
+	warmer := NewWarmer(cache, db, WarmerConfig{
+		Concurrency: 2,
+		Expiration:  time.Minute,
+		Encode: func(value string) (string, error) {
+			return "user:" + value, nil
+		},
+		Progress: func(processed, total int) {
+			mu.Lock()
+			defer mu.Unlock()
+			done = processed
+		},
+	})
+
+	stored, err := warmer.WarmKeys(ctx, "users", "id", "name", []string{"1", "3", "42"})
+	if err != nil {
+		t.Fatalf("WarmKeys() error = %v", err)
+	}
+	if stored != 2 {
+		t.Fatalf("WarmKeys() = %v, want %v", stored, 2)
+	}
+	if done != 3 {
+		t.Fatalf("progress = %v, want %v", done, 3)
+	}
+
+	if got, found, _ := cache.Get(ctx, "3"); !found || got != "user:Charlie" {
+		t.Fatalf("Get(3) = %v, %v, want the encoded value", got, found)
+	}
+	if _, found, _ := cache.Get(ctx, "2"); found {
+		t.Fatal("Get(2) should not be warmed")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := warmer.WarmKeys(cancelled, "users", "id", "name", []string{"1", "2"}); err == nil {
+		t.Fatal("WarmKeys() should fail on a cancelled context")
+	}
+}
+
+func TestWarmerSkipsExpiredRows(t *testing.T) {
+	cache, db := setupWarmer(t, "test_cache_warmer_expiry")
+	ctx := context.Background()
+
+	now := time.Now()
+	txn, _ := db.Begin(ctx)
+	db.Exec(ctx, txn, "CREATE TABLE sessions (id, name, expiresAt) WITH TYPES (string, string, int64)")
+	db.Exec(ctx, txn, "INSERT INTO sessions (id, name, expiresAt) VALUES (?, ?, ?), (?, ?, ?), (?, ?, ?)",
+		"expired", "Alice", now.Add(-time.Minute).Unix(),
+		"short", "Bob", now.Add(time.Minute).Unix(),
+		"forever", "Carol", 0)
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	warmer := NewWarmer(cache, db, WarmerConfig{ExpiresAtColumn: "expiresAt", Expiration: time.Hour})
+	keys := []string{"expired", "short", "forever"}
+	warm := map[string]func() (int, error){
+		"WarmTable": func() (int, error) { return warmer.WarmTable(ctx, "sessions", "id", "name") },
+		"WarmKeys":  func() (int, error) { return warmer.WarmKeys(ctx, "sessions", "id", "name", keys) },
+	}
+	for name, warm := range warm {
+		for _, key := range keys {
+			cache.Delete(ctx, key)
+		}
+
+		if stored, err := warm(); err != nil || stored != 2 {
+			t.Fatalf("%s() = %v, %v, want the 2 live rows", name, stored, err)
+		}
+		if _, found, _ := cache.Get(ctx, "expired"); found {
+			t.Fatalf("%s() cached an expired row", name)
+		}
+		for key, want := range map[string]time.Duration{"short": time.Minute, "forever": time.Hour} {
+			item, found := cache.items[key]
+			if !found {
+				t.Fatalf("%s() did not cache key %s", name, key)
+			}
+			if ttl := time.Unix(item.Expiration, 0).Sub(now); ttl < want-2*time.Second || ttl > want+time.Second {
+				t.Fatalf("%s() cached key %s for %v, want %v", name, key, ttl, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"own-database-cache/pkg/parser"
+	"strconv"
+	"strings"
+)
+
+var ErrArgCount = errors.New("number of arguments does not match placeholders")
+
+// bind substitutes the ? placeholders of sql with args written as literals.
+// Placeholders inside string literals are left untouched, without args sql is used as is.
+func bind(sql string, args []any) (string, error) {
+	if len(args) == 0 {
+		return sql, nil
+	}
+
+	var b strings.Builder
+	inQuotes := false
+	next := 0
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			inQuotes = !inQuotes
+		case c == '?' && !inQuotes:
+			if next >= len(args) {
+				return "", ErrArgCount
+			}
+			literal, err := formatArg(args[next])
+			if err != nil {
+				return "", fmt.Errorf("argument %d: %w", next+1, err)
+			}
+			b.WriteString(literal)
+			next++
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	if next != len(args) {
+		return "", ErrArgCount
+	}
+	return b.String(), nil
+}
+
+func formatArg(arg any) (string, error) {
+	switch v := arg.(type) {
+	case string:
+		return parser.Quote(v), nil
+	case []byte:
+		return parser.Quote(string(v)), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case bool:
+		return parser.Quote(strconv.FormatBool(v)), nil
+	case fmt.Stringer:
+		return parser.Quote(v.String()), nil
+	default:
+		return "", fmt.Errorf("unsupported argument type %T", arg)
+	}
+}
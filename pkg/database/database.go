@@ -4,155 +4,418 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
 	"own-database-cache/pkg/parser"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNoRows    = errors.New("no rows returned")
+	ErrNoRecords = errors.New("no records found")
+
+	errTransactionNotFound = errors.New("transaction not found")
 )
 
 type Transaction struct {
-	changes []func() error
+	changes []func(ctx context.Context) (*Change, error)
+	// tables are the tables the changes touch, restored when the commit fails.
+	tables []string
+}
+
+// Change describes the rows of Table modified by a statement. Old holds the deleted
+// rows and the updated ones as they were, New the inserted rows and the updated ones
+// as they are now. ALTER TABLE is reported without rows.
+type Change struct {
+	Table     string
+	Operation string
+	Columns   []string
+	Old       [][]string
+	New       [][]string
+}
+
+// Values returns the values of column in the changed rows, old ones first.
+func (c Change) Values(column string) (old, new []string) {
+	for i, name := range c.Columns {
+		if name != column {
+			continue
+		}
+		for _, row := range c.Old {
+			old = append(old, row[i])
+		}
+		for _, row := range c.New {
+			new = append(new, row[i])
+		}
+	}
+	return old, new
 }
 
 type Database struct {
-	mu           sync.Mutex
+	// lock is held from Begin until Commit or Rollback, waiting for it respects the context.
+	lock         chan struct{}
 	transactions map[*Transaction]bool
 	file         string
+
+	mu          sync.Mutex
+	subscribers []func(Change)
+	onSlow      func(query string, took time.Duration)
+	slowQuery   atomic.Int64
+	logger      *slog.Logger
+}
+
+type Option func(*Database)
+
+// WithLogger sets the logger of the database, slog.Default() by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Database) {
+		d.logger = logger
+	}
 }
 
-func NewDatabase(file string) *Database {
-	return &Database{
+func NewDatabase(file string, opts ...Option) *Database {
+	d := &Database{
+		lock:         make(chan struct{}, 1),
 		file:         file,
 		transactions: make(map[*Transaction]bool),
+		logger:       slog.Default(),
 	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
+// Begin waits until no other transaction is open, giving up when ctx is done.
 func (d *Database) Begin(ctx context.Context) (*Transaction, error) {
+	select {
+	case d.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	txn := &Transaction{changes: []func(ctx context.Context) (*Change, error){}}
 	d.mu.Lock()
-	txn := &Transaction{changes: []func() error{}}
 	d.transactions[txn] = true
+	d.mu.Unlock()
 	return txn, nil
 }
 
+// Commit applies the queued changes in order. When one fails or ctx is done part-way
+// the tables are restored, so either all changes are applied or none. Subscribers are
+// notified of the applied changes before the next transaction can begin, so commits
+// touching the same rows are published in the order they were applied.
 func (d *Database) Commit(ctx context.Context, txn *Transaction) error {
-	if _, ok := d.transactions[txn]; !ok {
-		d.mu.Unlock()
-		return errors.New("transaction not found")
+	if !d.end(txn) {
+		return errTransactionNotFound
+	}
+	defer d.unlock()
+
+	changes, err := d.commit(ctx, txn)
+	d.publish(changes...)
+	return err
+}
+
+func (d *Database) commit(ctx context.Context, txn *Transaction) ([]Change, error) {
+	snapshot, err := d.snapshot(txn.tables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot tables: %w", err)
+	}
+
+	var changes []Change
+	for _, apply := range txn.changes {
+		if err := ctx.Err(); err != nil {
+			return nil, snapshot.restore(err)
+		}
+		change, err := apply(ctx)
+		if err != nil {
+			return nil, snapshot.restore(err)
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
 	}
 
-	for _, change := range txn.changes {
-		if err := change(); err != nil {
-			d.mu.Unlock()
-			return err
+	return changes, nil
+}
+
+// tableSnapshot holds the files of tables as they were before a commit.
+type tableSnapshot map[string]tableFile
+
+type tableFile struct {
+	data   []byte
+	exists bool
+}
+
+func (d *Database) snapshot(tables []string) (tableSnapshot, error) {
+	snapshot := make(tableSnapshot)
+	for _, table := range tables {
+		path := d.file + table + ".csv"
+		if _, ok := snapshot[path]; ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
 		}
+		snapshot[path] = tableFile{data: data, exists: err == nil}
 	}
+	return snapshot, nil
+}
 
-	delete(d.transactions, txn)
-	d.mu.Unlock()
-	return nil
+// restore puts the tables back as they were and returns cause with the errors of doing so.
+func (s tableSnapshot) restore(cause error) error {
+	var errs []error
+	for path, file := range s {
+		var err error
+		if file.exists {
+			err = os.WriteFile(path, file.data, 0o644)
+		} else if err = os.Remove(path); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", path, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(append([]error{cause}, errs...)...)
+	}
+	return cause
 }
 
 func (d *Database) Rollback(ctx context.Context, txn *Transaction) error {
-	if _, ok := d.transactions[txn]; !ok {
-		d.mu.Unlock()
-		return errors.New("transaction not found")
+	if !d.end(txn) {
+		return errTransactionNotFound
 	}
+	d.unlock()
+	return nil
+}
 
+// end closes txn and reports whether it was open, only then does it hold the lock
+// taken by Begin. Closing a transaction twice must not release the lock of the next one.
+func (d *Database) end(txn *Transaction) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.transactions[txn] {
+		return false
+	}
 	delete(d.transactions, txn)
+	return true
+}
+
+// Subscribe registers fn to be called with every change applied to the tables, in
+// the goroutine that applied it. Changes of a transaction are published while it still
+// holds the commit lock, so fn must not begin a transaction.
+func (d *Database) Subscribe(fn func(Change)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subscribers = append(d.subscribers, fn)
+}
+
+// OnSlowQuery calls fn with the queries taking longer than threshold, zero disables it.
+func (d *Database) OnSlowQuery(threshold time.Duration, fn func(query string, took time.Duration)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onSlow = fn
+	d.SetSlowQueryThreshold(threshold)
+}
+
+// SetSlowQueryThreshold changes the threshold of OnSlowQuery while queries are running.
+func (d *Database) SetSlowQueryThreshold(threshold time.Duration) {
+	d.slowQuery.Store(int64(threshold))
+}
+
+// finished logs query and reports it to the OnSlowQuery callback when it was slow.
+func (d *Database) finished(query string, started time.Time) {
+	took := time.Since(started)
+	d.logger.Debug("query executed", "query", query, "took", took)
+
+	threshold := time.Duration(d.slowQuery.Load())
+	if threshold <= 0 || took <= threshold {
+		return
+	}
+
+	d.mu.Lock()
+	fn := d.onSlow
 	d.mu.Unlock()
-	return nil
+	if fn != nil {
+		fn(query, took)
+	}
 }
 
-func (d *Database) Exec(ctx context.Context, txn *Transaction, sql string) error {
+func (d *Database) publish(changes ...Change) {
+	d.mu.Lock()
+	subscribers := d.subscribers
+	d.mu.Unlock()
+
+	for _, change := range changes {
+		for _, fn := range subscribers {
+			fn(change)
+		}
+	}
+}
+
+func (d *Database) unlock() {
+	select {
+	case <-d.lock:
+	default:
+	}
+}
+
+// Exec queues sql to run on Commit. The ? placeholders of sql are replaced with args,
+// strings are quoted and escaped so any value is stored as is.
+func (d *Database) Exec(ctx context.Context, txn *Transaction, sql string, args ...any) error {
 	if txn == nil {
 		return errors.New("transaction is required")
 	}
 
-	change := func() error {
-		_, err := d.ExecuteQuery(sql)
+	sql, err := bind(sql, args)
+	if err != nil {
 		return err
 	}
+
+	change := func(ctx context.Context) (*Change, error) {
+		_, change, err := d.apply(ctx, sql)
+		return change, err
+	}
 	txn.changes = append(txn.changes, change)
+	// A query that does not parse fails on Commit without touching any table.
+	if parsedQuery, err := parser.ParseSQL(sql); err == nil {
+		txn.tables = append(txn.tables, parsedQuery.TableName)
+	}
 	return nil
 }
 
-func (d *Database) Query(ctx context.Context, sql string) ([][]string, error) {
-	return d.ExecuteQuery(sql)
+func (d *Database) Query(ctx context.Context, sql string, args ...any) ([][]string, error) {
+	sql, err := bind(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return d.execute(ctx, sql)
 }
 
-func (d *Database) QueryRow(ctx context.Context, sql string) ([]string, error) {
-	results, err := d.Query(ctx, sql)
+func (d *Database) QueryRow(ctx context.Context, sql string, args ...any) ([]string, error) {
+	results, err := d.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
-	if len(results) == 0 {
-		return nil, errors.New("no rows returned")
+	if len(results) < 2 {
+		return nil, ErrNoRows
 	}
 	return results[1], nil
 }
 
+// Columns returns the column names and types of table.
+func (d *Database) Columns(ctx context.Context, table string) ([]string, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return ReadTableStructure(d.file + table + ".csv")
+}
+
 func (d *Database) ExecuteQuery(query string) ([][]string, error) {
+	return d.execute(context.Background(), query)
+}
+
+func (d *Database) execute(ctx context.Context, query string) ([][]string, error) {
+	result, change, err := d.apply(ctx, query)
+	if change != nil {
+		d.publish(*change)
+	}
+	return result, err
+}
+
+// apply runs query and describes the rows it modified.
+func (d *Database) apply(ctx context.Context, query string) ([][]string, *Change, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	defer d.finished(query, time.Now())
+
 	parsedQuery, err := parser.ParseSQL(query)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	switch parsedQuery.Operation {
 	case "CREATE":
 		err = CreateTable(d.file, parsedQuery.TableName, parsedQuery.Columns, parsedQuery.Values[0])
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, nil
+		return nil, nil, nil
 	case "INSERT":
 		filePath := d.file + parsedQuery.TableName + ".csv"
 		header, types, err := ReadTableStructure(filePath)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		for _, values := range parsedQuery.Values {
 			for i, value := range values {
 				expectedType := types[i]
 				if err := checkDataType(value, expectedType); err != nil {
-					return nil, fmt.Errorf("error in column %s: %v", header[i], err)
+					return nil, nil, fmt.Errorf("error in column %s: %v", header[i], err)
 				}
 			}
 		}
 
 		err = InsertTable(filePath, parsedQuery.Values)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, nil
+		return nil, newChange(parsedQuery, header, nil, parsedQuery.Values), nil
 	case "DELETE":
 		filePath := d.file + parsedQuery.TableName + ".csv"
 		header, types, err := ReadTableStructure(filePath)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		err = DeleteTable(filePath, header, types, parsedQuery.WhereClause)
+		deleted, err := DeleteTable(ctx, filePath, header, types, parsedQuery.WhereClause)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, nil
+		return nil, newChange(parsedQuery, header, deleted, nil), nil
 	case "SELECT":
 		filePath := d.file + parsedQuery.TableName + ".csv"
-		return SelectTable(filePath, parsedQuery.Columns, parsedQuery.WhereClause, parsedQuery.OrderByClause)
+		result, err := SelectTable(ctx, filePath, parsedQuery.Columns, parsedQuery.WhereClause, parsedQuery.OrderByClause)
+		return result, nil, err
+	case "ALTER":
+		filePath := d.file + parsedQuery.TableName + ".csv"
+		err = AddColumn(filePath, parsedQuery.Columns[0], parsedQuery.Values[0][0])
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &Change{Table: parsedQuery.TableName, Operation: parsedQuery.Operation}, nil
 	case "UPDATE":
 		filePath := d.file + parsedQuery.TableName + ".csv"
 		header, _, err := ReadTableStructure(filePath)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		records, err := ReadTable(filePath)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		err = UpdateTable(filePath, header, records, parsedQuery.Values[0], parsedQuery.WhereClause)
+		before, after, err := UpdateTable(ctx, filePath, header, records, parsedQuery.Values[0], parsedQuery.WhereClause)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, nil
+		return nil, newChange(parsedQuery, header, before, after), nil
 	}
 
-	return nil, errors.New("unsupported operation")
+	return nil, nil, errors.New("unsupported operation")
+}
+
+// change returns nil when no rows were modified.
+func newChange(query parser.ParsedQuery, header []string, old, new [][]string) *Change {
+	if len(old) == 0 && len(new) == 0 {
+		return nil
+	}
+	return &Change{Table: query.TableName, Operation: query.Operation, Columns: header, Old: old, New: new}
 }
@@ -2,23 +2,23 @@ package database
 
 import (
 	"context"
+	"errors"
 	"own-database-cache/internal/config"
 	"os"
 	"reflect"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 )
 
-const configPath = "../../config.json"
-
 func TestTransaction(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	testdatabaseDir := config.PathConfig.TestDatabaseFilePath
+	testdatabaseDir := config.PathConfig.DatabaseFilePath
 
 	db := NewDatabase(testdatabaseDir)
 	ctx := context.Background()
@@ -43,12 +43,12 @@ func TestTransaction(t *testing.T) {
 }
 
 func TestConcurrentTransactions(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	testdatabaseDir := config.PathConfig.TestDatabaseFilePath
+	testdatabaseDir := config.PathConfig.DatabaseFilePath
 	db := NewDatabase(testdatabaseDir)
 	ctx := context.Background()
 
@@ -76,12 +76,12 @@ func TestConcurrentTransactions(t *testing.T) {
 }
 
 func TestExec(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	testdatabaseDir := config.PathConfig.TestDatabaseFilePath
+	testdatabaseDir := config.PathConfig.DatabaseFilePath
 
 	db := NewDatabase(testdatabaseDir)
 	ctx := context.Background()
@@ -113,12 +113,12 @@ func TestExec(t *testing.T) {
 }
 
 func TestQuery(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	testdatabaseDir := config.PathConfig.TestDatabaseFilePath
+	testdatabaseDir := config.PathConfig.DatabaseFilePath
 
 	db := NewDatabase(testdatabaseDir)
 	ctx := context.Background()
@@ -153,12 +153,12 @@ func TestQuery(t *testing.T) {
 }
 
 func TestQueryRow(t *testing.T) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		t.Fatalf("Error reading config: %v", err)
 	}
 
-	testdatabaseDir := config.PathConfig.TestDatabaseFilePath
+	testdatabaseDir := config.PathConfig.DatabaseFilePath
 
 	db := NewDatabase(testdatabaseDir)
 	ctx := context.Background()
@@ -186,3 +186,277 @@ func TestQueryRow(t *testing.T) {
 		t.Fatalf("Expected %v, got %v", expectedRow, row)
 	}
 }
+
+func TestExecWithArgs(t *testing.T) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	testdatabaseDir := config.PathConfig.DatabaseFilePath
+
+	db := NewDatabase(testdatabaseDir)
+	ctx := context.Background()
+	defer os.RemoveAll(testdatabaseDir)
+
+	values := []string{
+		"it's",
+		"a, b",
+		`"quoted"`,
+		"(1), (2)",
+		"two\nlines",
+		"  spaced  ",
+		"x ORDER BY id",
+		"?",
+		"",
+	}
+
+	txn, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	db.Exec(ctx, txn, "CREATE TABLE test (id, name) WITH TYPES (int64, string)")
+	for i, value := range values {
+		if err := db.Exec(ctx, txn, "INSERT INTO test (id, name) VALUES (?, ?)", i, value); err != nil {
+			t.Fatalf("Failed to execute query: %v", err)
+		}
+	}
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+
+	for i, value := range values {
+		row, err := db.QueryRow(ctx, "SELECT id, name FROM test WHERE name = ?", value)
+		if err != nil {
+			t.Fatalf("QueryRow(%q) error: %v", value, err)
+		}
+		expectedRow := []string{strconv.Itoa(i), value}
+		if !reflect.DeepEqual(row, expectedRow) {
+			t.Fatalf("Expected %q, got %q", expectedRow, row)
+		}
+	}
+
+	if _, err := db.Query(ctx, "SELECT id FROM test WHERE name = ?", "a", "b"); !errors.Is(err, ErrArgCount) {
+		t.Fatalf("Expected %v, got %v", ErrArgCount, err)
+	}
+}
+
+func TestBeginRespectsContext(t *testing.T) {
+	db := NewDatabase(t.TempDir() + "/")
+	ctx := context.Background()
+
+	txn, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := db.Begin(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v while another transaction is open, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Begin() returned after %v", elapsed)
+	}
+
+	if err := db.Rollback(ctx, txn); err != nil {
+		t.Fatalf("Failed to rollback transaction: %v", err)
+	}
+	txn, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction after rollback: %v", err)
+	}
+	db.Rollback(ctx, txn)
+}
+
+// countdownContext reports cancellation after its Err method has been called n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestQueryCanceledMidScan(t *testing.T) {
+	db := NewDatabase(t.TempDir() + "/")
+	ctx := context.Background()
+
+	txn, _ := db.Begin(ctx)
+	db.Exec(ctx, txn, "CREATE TABLE test (id, name) WITH TYPES (int64, string)")
+	for i := 0; i < 1000; i++ {
+		db.Exec(ctx, txn, "INSERT INTO test (id, name) VALUES (?, ?)", i, "name")
+	}
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+
+	// The first checks happen before the query and at the first row, the third one mid-scan.
+	if _, err := db.Query(&countdownContext{Context: ctx, n: 2}, "SELECT id FROM test"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := db.Query(canceled, "SELECT id FROM test"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	txn, _ = db.Begin(ctx)
+	db.Exec(ctx, txn, "DELETE FROM test")
+	if err := db.Commit(canceled, txn); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+	rows, err := db.Query(ctx, "SELECT id FROM test")
+	if err != nil || len(rows) != 1001 {
+		t.Fatalf("Canceled commit should not apply changes, got %d rows, %v", len(rows), err)
+	}
+}
+
+func TestCommitCanceledAfterChange(t *testing.T) {
+	db := NewDatabase(t.TempDir() + "/")
+	ctx := context.Background()
+
+	txn, _ := db.Begin(ctx)
+	db.Exec(ctx, txn, "CREATE TABLE test (id, name) WITH TYPES (int64, string)")
+	db.Exec(ctx, txn, "INSERT INTO test (id, name) VALUES (?, ?)", 1, "old")
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+
+	var published []Change
+	db.Subscribe(func(change Change) {
+		published = append(published, change)
+	})
+
+	// The first change of the commit is applied, then the context is canceled.
+	canceled, cancel := context.WithCancel(ctx)
+	db.OnSlowQuery(time.Nanosecond, func(query string, took time.Duration) {
+		cancel()
+	})
+	txn, _ = db.Begin(ctx)
+	db.Exec(ctx, txn, "DELETE FROM test WHERE id = ?", 1)
+	db.Exec(ctx, txn, "INSERT INTO test (id, name) VALUES (?, ?)", 1, "new")
+	db.Exec(ctx, txn, "CREATE TABLE other (id) WITH TYPES (int64)")
+	if err := db.Commit(canceled, txn); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+	db.OnSlowQuery(0, nil)
+
+	rows, err := db.Query(ctx, "SELECT id, name FROM test")
+	if err != nil || !reflect.DeepEqual(rows, [][]string{{"id", "name"}, {"1", "old"}}) {
+		t.Fatalf("Canceled commit should keep the table as it was, got %q, %v", rows, err)
+	}
+	if len(published) != 0 {
+		t.Fatalf("Canceled commit should not publish changes, got %+v", published)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	db := NewDatabase(t.TempDir() + "/")
+	ctx := context.Background()
+
+	var changes []Change
+	db.Subscribe(func(change Change) {
+		// The next transaction waits until the subscribers are notified.
+		waiting, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := db.Begin(waiting); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Begin() in a subscriber error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		changes = append(changes, change)
+	})
+
+	txn, _ := db.Begin(ctx)
+	db.Exec(ctx, txn, "CREATE TABLE users (id, name) WITH TYPES (string, string)")
+	db.Exec(ctx, txn, "INSERT INTO users (id, name) VALUES ('1', 'Alice'), ('2', 'Bob')")
+	db.Exec(ctx, txn, "UPDATE users SET name = 'Carol' WHERE id = '2'")
+	db.Exec(ctx, txn, "DELETE FROM users WHERE id = '1'")
+	db.Exec(ctx, txn, "DELETE FROM users WHERE id = '3'")
+	if err := db.Commit(ctx, txn); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	want := []Change{
+		{Table: "users", Operation: "INSERT", Columns: []string{"id", "name"}, New: [][]string{{"1", "Alice"}, {"2", "Bob"}}},
+		{Table: "users", Operation: "UPDATE", Columns: []string{"id", "name"}, Old: [][]string{{"2", "Bob"}}, New: [][]string{{"2", "Carol"}}},
+		{Table: "users", Operation: "DELETE", Columns: []string{"id", "name"}, Old: [][]string{{"1", "Alice"}}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+
+	old, new := changes[1].Values("name")
+	if !reflect.DeepEqual(old, []string{"Bob"}) || !reflect.DeepEqual(new, []string{"Carol"}) {
+		t.Fatalf("Values() = %v, %v", old, new)
+	}
+
+	rows, err := db.Query(ctx, "SELECT id, name FROM users")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if !reflect.DeepEqual(rows[1:], [][]string{{"2", "Carol"}}) {
+		t.Fatalf("Query() = %v, the types row should not be updated", rows)
+	}
+}
+
+func TestSlowQuery(t *testing.T) {
+	db := NewDatabase(t.TempDir() + "/")
+	ctx := context.Background()
+	db.ExecuteQuery("CREATE TABLE users (id, name) WITH TYPES (string, string)")
+
+	var slow []string
+	db.OnSlowQuery(time.Hour, func(query string, took time.Duration) {
+		slow = append(slow, query)
+	})
+	db.Query(ctx, "SELECT id FROM users")
+	if len(slow) != 0 {
+		t.Fatalf("slow = %v, no query should take an hour", slow)
+	}
+
+	db.SetSlowQueryThreshold(time.Nanosecond)
+	db.Query(ctx, "SELECT id FROM users")
+	if len(slow) != 1 || slow[0] != "SELECT id FROM users" {
+		t.Fatalf("slow = %v, want the query over the lowered threshold", slow)
+	}
+}
+
+func TestClosedTransactionKeepsLock(t *testing.T) {
+	db := NewDatabase(t.TempDir() + "/")
+	ctx := context.Background()
+
+	first, _ := db.Begin(ctx)
+	if err := db.Commit(ctx, first); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	second, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+
+	if err := db.Commit(ctx, first); err == nil {
+		t.Fatal("Commit() of a closed transaction should fail")
+	}
+	if err := db.Rollback(ctx, first); err == nil {
+		t.Fatal("Rollback() of a closed transaction should fail")
+	}
+
+	waiting, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.Begin(waiting); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Begin() error = %v, the open transaction should still hold the lock", err)
+	}
+
+	if err := db.Rollback(ctx, second); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if _, err := db.Begin(ctx); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+}
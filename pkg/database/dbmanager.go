@@ -1,10 +1,13 @@
 package database
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"own-database-cache/pkg/parser"
 	"strings"
 )
 
@@ -37,55 +40,107 @@ func CreateTable(dbFile, tableName string, columns, dataTypes []string) error {
 	return err
 }
 
-func UpdateTable(filePath string, header []string, records [][]string, setClauses []string, whereClause string) error {
-	for i := 1; i < len(records); i++ {
-		if whereClause == "" || EvaluateWhere(records[i], header, whereClause) {
-			for _, set := range setClauses {
-				parts := strings.SplitN(set, "=", 2)
-				if len(parts) != 2 {
-					return errors.New("неверный SET-клауз")
-				}
-				column := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				value = strings.Trim(value, "'")
-
-				for j, h := range header {
-					if h == column {
-						records[i][j] = value
-						break
-					}
+// AddColumn appends a column to the table, existing rows get the zero value of dataType.
+func AddColumn(filePath, column, dataType string) error {
+	if _, err := zeroValue(dataType); err != nil {
+		return err
+	}
+
+	records, err := ReadTable(filePath)
+	if err != nil {
+		return err
+	}
+	if len(records) < 2 {
+		return errors.New("invalid table structure")
+	}
+
+	for _, h := range records[0] {
+		if h == column {
+			return fmt.Errorf("column %s already exists", column)
+		}
+	}
+
+	value, _ := zeroValue(dataType)
+	records[0] = append(records[0], column)
+	records[1] = append(records[1], dataType)
+	for i := 2; i < len(records); i++ {
+		records[i] = append(records[i], value)
+	}
+
+	return saveRecordsToFile(filePath, records)
+}
+
+// UpdateTable applies setClauses to the records matching whereClause and saves the table.
+// It returns the matched rows before and after the update.
+func UpdateTable(ctx context.Context, filePath string, header []string, records [][]string, setClauses []string, whereClause string) (before, after [][]string, err error) {
+	for i := 2; i < len(records); i++ {
+		if err := checkContext(ctx, i); err != nil {
+			return nil, nil, err
+		}
+		if whereClause != "" && !EvaluateWhere(records[i], header, whereClause) {
+			continue
+		}
+
+		old := append([]string(nil), records[i]...)
+		for _, set := range setClauses {
+			parts := strings.SplitN(set, "=", 2)
+			if len(parts) != 2 {
+				return nil, nil, errors.New("неверный SET-клауз")
+			}
+			column := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+			value = parser.Unquote(value)
+
+			for j, h := range header {
+				if h == column {
+					records[i][j] = value
+					break
 				}
 			}
 		}
+		if len(setClauses) > 0 {
+			before = append(before, old)
+			after = append(after, records[i])
+		}
 	}
 
-	return saveRecordsToFile(filePath, records)
+	return before, after, saveRecordsToFile(filePath, records)
 }
 
-func DeleteTable(filePath string, header, types []string, whereClause string) error {
+// DeleteTable removes the records matching whereClause and returns them.
+func DeleteTable(ctx context.Context, filePath string, header, types []string, whereClause string) ([][]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	records, err := reader.ReadAll()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var remainingRecords [][]string
 	remainingRecords = append(remainingRecords, header)
 	remainingRecords = append(remainingRecords, types)
 
-	for _, record := range records[2:] {
+	var deleted [][]string
+	for i, record := range records[2:] {
+		if err := checkContext(ctx, i); err != nil {
+			return nil, err
+		}
 		if whereClause == "" || !EvaluateWhere(record, header, whereClause) {
 			remainingRecords = append(remainingRecords, record)
+		} else {
+			deleted = append(deleted, record)
 		}
 	}
 
-	return UpdateTable(filePath, header, remainingRecords, []string{}, "")
+	if _, _, err := UpdateTable(ctx, filePath, header, remainingRecords, []string{}, ""); err != nil {
+		return nil, err
+	}
+	return deleted, nil
 }
 
 func InsertTable(filePath string, values [][]string) error {
@@ -95,17 +150,16 @@ func InsertTable(filePath string, values [][]string) error {
 	}
 	defer file.Close()
 
-	for _, value := range values {
-		_, err = file.WriteString(strings.Join(value, ",") + "\n")
-		if err != nil {
-			return err
-		}
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(values); err != nil {
+		return err
 	}
 
-	return nil
+	return writer.Error()
 }
 
-func SelectTable(filePath string, columns []string, whereClause, orderByClause string) ([][]string, error) {
+// SelectTable scans the table row by row and gives up once ctx is done.
+func SelectTable(ctx context.Context, filePath string, columns []string, whereClause, orderByClause string) ([][]string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
@@ -113,19 +167,33 @@ func SelectTable(filePath string, columns []string, whereClause, orderByClause s
 	defer file.Close()
 
 	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	header, err := reader.Read()
+	if err == nil {
+		_, err = reader.Read()
+	}
+	if err == io.EOF {
+		return nil, ErrNoRecords
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if len(records) < 3 {
-		return nil, errors.New("no records found")
-	}
-
-	header := records[0]
 	var resultRecords [][]string
 	resultRecords = append(resultRecords, columns)
-	for _, record := range records[2:] {
+	rows := 0
+	for ; ; rows++ {
+		if err := checkContext(ctx, rows); err != nil {
+			return nil, err
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
 		if whereClause != "" && !EvaluateWhere(record, header, whereClause) {
 			continue
 		}
@@ -141,6 +209,10 @@ func SelectTable(filePath string, columns []string, whereClause, orderByClause s
 		resultRecords = append(resultRecords, resultRecord)
 	}
 
+	if rows == 0 {
+		return nil, ErrNoRecords
+	}
+
 	if orderByClause != "" {
 		resultRecords, err = OrderBy(resultRecords, orderByClause)
 	}
@@ -10,15 +10,13 @@ import (
 	"testing"
 )
 
-const configPath = "../../config.json"
-
 func setup() (*db.Database, context.Context, func()) {
-	config, err := config.LoadConfig(configPath)
+	config, err := config.LoadTest()
 	if err != nil {
 		log.Fatalf("Error reading config: %v", err)
 	}
 
-	testdatabaseDir := config.PathConfig.TestDatabaseFilePath
+	testdatabaseDir := config.PathConfig.DatabaseFilePath
 
 	db := db.NewDatabase(testdatabaseDir)
 	ctx := context.Background()
@@ -37,12 +35,12 @@ func TestCreateTable(t *testing.T) {
 	defer teardown()
 
 	t.Run("CreateTable_Success", func(t *testing.T) {
-		config, err := config.LoadConfig(configPath)
+		config, err := config.LoadTest()
 		if err != nil {
 			log.Fatalf("Error reading config: %v", err)
 		}
 
-		testdatabaseDir := config.PathConfig.TestCacheFilePath
+		testdatabaseDir := config.PathConfig.CacheFilePath
 		txn, err := db.Begin(ctx)
 		if err != nil {
 			t.Fatalf("Failed to begin transaction: %v", err)
@@ -389,3 +387,54 @@ func TestUpdate(t *testing.T) {
 		}
 	})
 }
+
+func TestAlterTable(t *testing.T) {
+	db, ctx, teardown := setup()
+	defer teardown()
+
+	queries := []string{
+		"CREATE TABLE test (id, name) WITH TYPES (int64, string)",
+		"INSERT INTO test (id, name) VALUES (1, 'Alice')",
+		"ALTER TABLE test ADD COLUMN age int64",
+	}
+	for _, query := range queries {
+		txn, err := db.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		if err := db.Exec(ctx, txn, query); err != nil {
+			t.Fatalf("Failed to execute query: %v", err)
+		}
+		if err := db.Commit(ctx, txn); err != nil {
+			t.Fatalf("Failed to commit transaction: %v", err)
+		}
+	}
+
+	columns, types, err := db.Columns(ctx, "test")
+	if err != nil {
+		t.Fatalf("Failed to read columns: %v", err)
+	}
+	if !reflect.DeepEqual(columns, []string{"id", "name", "age"}) || !reflect.DeepEqual(types, []string{"int64", "string", "int64"}) {
+		t.Fatalf("Unexpected structure %v %v", columns, types)
+	}
+
+	result, err := db.Query(ctx, "SELECT id, age FROM test")
+	if err != nil {
+		t.Fatalf("Failed to select data: %v", err)
+	}
+	expected := [][]string{{"id", "age"}, {"1", "0"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Expected %v, got %v", expected, result)
+	}
+
+	txn, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := db.Exec(ctx, txn, "ALTER TABLE test ADD COLUMN age int64"); err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if err := db.Commit(ctx, txn); err == nil {
+		t.Fatal("Expected error when adding an existing column, but got nil")
+	}
+}
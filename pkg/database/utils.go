@@ -2,9 +2,12 @@ package database
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"errors"
 	"os"
+	"own-database-cache/pkg/parser"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -24,6 +27,28 @@ func checkDataType(value, dataType string) error {
 	return nil
 }
 
+// contextCheckInterval is the number of rows scanned between checks of the context.
+const contextCheckInterval = 256
+
+// checkContext returns ctx.Err() every contextCheckInterval rows of a scan.
+func checkContext(ctx context.Context, row int) error {
+	if row%contextCheckInterval != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+func zeroValue(dataType string) (string, error) {
+	switch dataType {
+	case "int64":
+		return "0", nil
+	case "string":
+		return "", nil
+	default:
+		return "", errors.New("unsupported data type")
+	}
+}
+
 func ReadTableStructure(filePath string) (header []string, types []string, err error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -107,12 +132,14 @@ func OrderBy(records [][]string, orderByClause string) ([][]string, error) {
 	return records, nil
 }
 
+var conditionPattern = regexp.MustCompile(`(?s)^(\S+)\s+(\S+)\s+(.*)$`)
+
 func EvaluateWhere(record []string, header []string, whereClause string) bool {
-	parts := strings.Fields(whereClause)
-	if len(parts) != 3 {
+	parts := conditionPattern.FindStringSubmatch(strings.TrimSpace(whereClause))
+	if parts == nil {
 		return false
 	}
-	column, operator, value := parts[0], parts[1], parts[2]
+	column, operator, value := parts[1], parts[2], parser.Unquote(parts[3])
 
 	colIndex := -1
 	for i, h := range header {
@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+)
+
+func handleAlter(query string) (ParsedQuery, error) {
+	re := regexp.MustCompile(`ALTER TABLE (\w+)\s+ADD\s+COLUMN\s+(\w+)\s+(\w+)`)
+	matches := re.FindStringSubmatch(query)
+	if len(matches) != 4 {
+		return ParsedQuery{}, errors.New("invalid ALTER TABLE query")
+	}
+
+	return ParsedQuery{
+		Operation: "ALTER",
+		TableName: matches[1],
+		Columns:   []string{matches[2]},
+		Values:    [][]string{{matches[3]}},
+	}, nil
+}
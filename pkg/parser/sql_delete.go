@@ -6,8 +6,8 @@ import (
 )
 
 func handleDelete(query string) (ParsedQuery, error) {
-	re := regexp.MustCompile(`DELETE\s+FROM\s+(\w+)(?:\s+WHERE\s+(.*))?`)
-	matches := re.FindStringSubmatch(query)
+	re := regexp.MustCompile(`(?s)DELETE\s+FROM\s+(\w+)(?:\s+WHERE\s+(.*))?`)
+	matches := matchOutsideQuotes(re, query)
 	if matches == nil {
 		return ParsedQuery{}, errors.New("invalid DELETE query")
 	}
@@ -7,8 +7,8 @@ import (
 )
 
 func handleInsert(query string) (ParsedQuery, error) {
-	re := regexp.MustCompile(`INSERT INTO (\w+)\s*\(([^)]+)\)\s*VALUES\s*(.*)`)
-	matches := re.FindStringSubmatch(query)
+	re := regexp.MustCompile(`(?s)INSERT INTO (\w+)\s*\(([^)]+)\)\s*VALUES\s*(.*)`)
+	matches := matchOutsideQuotes(re, query)
 	if len(matches) != 4 {
 		return ParsedQuery{}, errors.New("invalid INSERT INTO query")
 	}
@@ -17,7 +17,7 @@ func handleInsert(query string) (ParsedQuery, error) {
 	columns := strings.Split(matches[2], ",")
 	valuesPart := matches[3]
 
-	valuesGroups := strings.Split(valuesPart, "),")
+	valuesGroups := splitOutsideQuotes(valuesPart, "),")
 	var values [][]string
 	for _, valuesGroup := range valuesGroups {
 		value, err := ParseValues(valuesGroup)
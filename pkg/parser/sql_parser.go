@@ -32,6 +32,8 @@ func ParseSQL(query string) (ParsedQuery, error) {
 		return handleDelete(query)
 	case "CREATE":
 		return handleCreate(query)
+	case "ALTER":
+		return handleAlter(query)
 	default:
 		return parsedQuery, errors.New("unsupported query")
 	}
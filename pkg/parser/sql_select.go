@@ -7,8 +7,8 @@ import (
 )
 
 func handleSelect(query string) (ParsedQuery, error) {
-	re := regexp.MustCompile(`SELECT\s+(.*?)\s+FROM\s+(\w+)(?:\s+WHERE\s+(.*?))?(?:\s+ORDER\s+BY\s+(.*?))?$`)
-	matches := re.FindStringSubmatch(query)
+	re := regexp.MustCompile(`(?s)SELECT\s+(.*?)\s+FROM\s+(\w+)(?:\s+WHERE\s+(.*?))?(?:\s+ORDER\s+BY\s+(.*?))?$`)
+	matches := matchOutsideQuotes(re, query)
 	if matches == nil {
 		return ParsedQuery{}, errors.New("invalid SELECT query")
 	}
@@ -7,8 +7,8 @@ import (
 )
 
 func handleUpdate(query string) (ParsedQuery, error) {
-	re := regexp.MustCompile(`UPDATE\s+(\w+)\s+SET\s+(.*?)(?:\s+WHERE\s+(.*))?$`)
-	matches := re.FindStringSubmatch(query)
+	re := regexp.MustCompile(`(?s)UPDATE\s+(\w+)\s+SET\s+(.*?)(?:\s+WHERE\s+(.*))?$`)
+	matches := matchOutsideQuotes(re, query)
 	if matches == nil {
 		return ParsedQuery{}, errors.New("неверный запрос UPDATE")
 	}
@@ -20,7 +20,7 @@ func handleUpdate(query string) (ParsedQuery, error) {
 		whereClause = matches[3]
 	}
 
-	setParts := strings.Split(setClause, ",")
+	setParts := splitOutsideQuotes(setClause, ",")
 	var sets []string
 	for _, part := range setParts {
 		set := strings.TrimSpace(part)
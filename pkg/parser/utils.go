@@ -3,42 +3,48 @@ package parser
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 )
 
 func ParseValues(input string) ([]string, error) {
 	var values []string
-	var value string
-	inQuotes := false
+	var value strings.Builder
+	inQuotes, quoted := false, false
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if inQuotes {
+			if r != '\'' {
+				value.WriteRune(r)
+				continue
+			}
+			// '' inside a quoted value is an escaped quote.
+			if i+1 < len(runes) && runes[i+1] == '\'' {
+				value.WriteRune(r)
+				i++
+				continue
+			}
+			values = append(values, value.String())
+			value.Reset()
+			inQuotes, quoted = false, true
+			continue
+		}
 
-	for _, r := range input {
 		switch r {
 		case '\'':
-			if inQuotes {
-				trimmedValue := strings.TrimSpace(value)
-				values = append(values, trimmedValue)
-				value = ""
-			}
-			inQuotes = !inQuotes
+			value.Reset()
+			inQuotes = true
 		case ',':
-			if !inQuotes {
-				trimmedValue := strings.TrimSpace(value)
-				if trimmedValue != "" {
-					values = append(values, strings.Trim(trimmedValue, "()"))
-				}
-				value = ""
-			} else {
-				value += string(r)
-			}
-		case '(':
-			if !inQuotes {
-				continue
+			if trimmedValue := strings.TrimSpace(value.String()); !quoted && trimmedValue != "" {
+				values = append(values, trimmedValue)
 			}
-			value += string(r)
+			value.Reset()
+			quoted = false
+		case '(', ')':
 		default:
-			if inQuotes || r != ')' {
-				value += string(r)
-			}
+			value.WriteRune(r)
 		}
 	}
 
@@ -46,14 +52,74 @@ func ParseValues(input string) ([]string, error) {
 		return nil, errors.New("mismatched quotes in values")
 	}
 
-	if value != "" {
-		trimmedValue := strings.TrimSpace(value)
-		values = append(values, strings.Trim(trimmedValue, "()"))
+	if trimmedValue := strings.TrimSpace(value.String()); !quoted && trimmedValue != "" {
+		values = append(values, trimmedValue)
 	}
 
 	return values, nil
 }
 
+// Quote returns s as a string literal, quotes inside it are doubled.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Unquote reverses Quote, values that are not quoted are returned as is.
+func Unquote(s string) string {
+	if len(s) < 2 || s[0] != '\'' || s[len(s)-1] != '\'' {
+		return s
+	}
+	return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+}
+
+// maskQuoted replaces everything inside string literals with a placeholder of the same
+// length, so keywords and separators can be searched for without looking into values.
+func maskQuoted(s string) string {
+	masked := []byte(s)
+	inQuotes := false
+	for i := 0; i < len(masked); i++ {
+		if masked[i] == '\'' {
+			inQuotes = !inQuotes
+			continue
+		}
+		if inQuotes {
+			masked[i] = '_'
+		}
+	}
+	return string(masked)
+}
+
+// matchOutsideQuotes works like re.FindStringSubmatch but ignores matches inside string literals.
+func matchOutsideQuotes(re *regexp.Regexp, s string) []string {
+	indexes := re.FindStringSubmatchIndex(maskQuoted(s))
+	if indexes == nil {
+		return nil
+	}
+
+	matches := make([]string, len(indexes)/2)
+	for i := range matches {
+		if indexes[2*i] >= 0 {
+			matches[i] = s[indexes[2*i]:indexes[2*i+1]]
+		}
+	}
+	return matches
+}
+
+// splitOutsideQuotes works like strings.Split but ignores separators inside string literals.
+func splitOutsideQuotes(s, sep string) []string {
+	masked := maskQuoted(s)
+
+	var parts []string
+	for {
+		i := strings.Index(masked, sep)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s, masked = s[i+len(sep):], masked[i+len(sep):]
+	}
+}
+
 func ParseWhereClause(whereClause string, header []string) (int64, string, error) {
 	parts := strings.Split(whereClause, "=")
 	if len(parts) != 2 {
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrExceedsLimit = errors.New("requested tokens exceed limiter capacity")
+	ErrInvalidCount = errors.New("number of requested tokens must be positive")
+)
+
+// Result describes the outcome of a single Allow call.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Reservation is returned by Reserve: the tokens are already taken and the caller
+// is expected to wait Delay before acting.
+type Reservation struct {
+	Delay     time.Duration
+	Limit     int
+	Remaining int
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	AllowN(ctx context.Context, key string, n int) (Result, error)
+	Reserve(ctx context.Context, key string) (Reservation, error)
+	ReserveN(ctx context.Context, key string, n int) (Reservation, error)
+	Remaining(ctx context.Context, key string) (int, error)
+}
+
+// stateTTL rounds d up to whole seconds, the resolution of cache expiration, with one second to spare.
+func stateTTL(d time.Duration) time.Duration {
+	if d < 0 {
+		d = 0
+	}
+	return d.Truncate(time.Second) + 2*time.Second
+}
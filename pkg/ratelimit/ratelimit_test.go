@@ -0,0 +1,249 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"own-database-cache/internal/config"
+	pkg "own-database-cache/pkg/cache"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func setup(t *testing.T, name string) (*pkg.Cache, *fakeClock) {
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+
+	file := config.PathConfig.CacheFilePath + name
+	t.Cleanup(func() {
+		os.Remove(file)
+	})
+
+	return pkg.NewCache(file), &fakeClock{now: time.Now()}
+}
+
+func newTokenBucket(t *testing.T, cache *pkg.Cache, cfg TokenBucketConfig) *TokenBucket {
+	limiter, err := NewTokenBucket(cache, cfg)
+	if err != nil {
+		t.Fatalf("NewTokenBucket() error = %v", err)
+	}
+	return limiter
+}
+
+func newSlidingWindow(t *testing.T, cache *pkg.Cache, cfg SlidingWindowConfig) *SlidingWindow {
+	limiter, err := NewSlidingWindow(cache, cfg)
+	if err != nil {
+		t.Fatalf("NewSlidingWindow() error = %v", err)
+	}
+	return limiter
+}
+
+func TestTokenBucketAllow(t *testing.T) {
+	cache, clock := setup(t, "test_ratelimit_token_bucket.csv")
+	limiter := newTokenBucket(t, cache, TokenBucketConfig{Rate: 1, Burst: 3})
+	limiter.now = clock.Now
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(ctx, "user")
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("Allow() #%d was rejected", i)
+		}
+		if result.Remaining != 2-i {
+			t.Fatalf("Remaining = %v, want %v", result.Remaining, 2-i)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, "user")
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("Allow() should reject when the bucket is empty")
+	}
+	if result.RetryAfter != time.Second {
+		t.Fatalf("RetryAfter = %v, want %v", result.RetryAfter, time.Second)
+	}
+
+	other, err := limiter.Allow(ctx, "other")
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if !other.Allowed {
+		t.Fatal("Allow() for another key should not share the bucket")
+	}
+
+	clock.Advance(2 * time.Second)
+
+	remaining, err := limiter.Remaining(ctx, "user")
+	if err != nil {
+		t.Fatalf("Remaining() error = %v", err)
+	}
+	if remaining != 2 {
+		t.Fatalf("Remaining() = %v, want %v", remaining, 2)
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	cache, clock := setup(t, "test_ratelimit_token_bucket_reserve.csv")
+	limiter := newTokenBucket(t, cache, TokenBucketConfig{Rate: 2, Burst: 2})
+	limiter.now = clock.Now
+	ctx := context.Background()
+
+	delays := []time.Duration{0, 0, 500 * time.Millisecond, time.Second}
+	for i, want := range delays {
+		reservation, err := limiter.Reserve(ctx, "user")
+		if err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+		if reservation.Delay != want {
+			t.Fatalf("Reserve() #%d delay = %v, want %v", i, reservation.Delay, want)
+		}
+	}
+
+	if _, err := limiter.ReserveN(ctx, "user", 3); err != ErrExceedsLimit {
+		t.Fatalf("ReserveN() error = %v, want %v", err, ErrExceedsLimit)
+	}
+}
+
+func TestTokenBucketPersistence(t *testing.T) {
+	cache, clock := setup(t, "test_ratelimit_token_bucket_persistence.csv")
+	limiter := newTokenBucket(t, cache, TokenBucketConfig{Rate: 1, Burst: 2})
+	limiter.now = clock.Now
+	ctx := context.Background()
+
+	if _, err := limiter.AllowN(ctx, "user", 2); err != nil {
+		t.Fatalf("AllowN() error = %v", err)
+	}
+
+	config, err := config.LoadTest()
+	if err != nil {
+		t.Fatalf("Error reading config: %v", err)
+	}
+	reloaded := newTokenBucket(t, pkg.NewCache(config.PathConfig.CacheFilePath+"test_ratelimit_token_bucket_persistence.csv"), TokenBucketConfig{Rate: 1, Burst: 2})
+	reloaded.now = clock.Now
+
+	result, err := reloaded.Allow(ctx, "user")
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("Allow() should see the state persisted by the previous limiter")
+	}
+}
+
+func TestSlidingWindowAllow(t *testing.T) {
+	cache, clock := setup(t, "test_ratelimit_sliding_window.csv")
+	limiter := newSlidingWindow(t, cache, SlidingWindowConfig{Limit: 2, Window: 10 * time.Second})
+	limiter.now = clock.Now
+	ctx := context.Background()
+
+	if result, err := limiter.Allow(ctx, "user"); err != nil || !result.Allowed {
+		t.Fatalf("Allow() = %v, %v", result, err)
+	}
+	clock.Advance(4 * time.Second)
+	if result, err := limiter.Allow(ctx, "user"); err != nil || !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("Allow() = %v, %v", result, err)
+	}
+
+	result, err := limiter.Allow(ctx, "user")
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("Allow() should reject the third event in the window")
+	}
+	if result.RetryAfter != 6*time.Second {
+		t.Fatalf("RetryAfter = %v, want %v", result.RetryAfter, 6*time.Second)
+	}
+
+	clock.Advance(6 * time.Second)
+
+	remaining, err := limiter.Remaining(ctx, "user")
+	if err != nil {
+		t.Fatalf("Remaining() error = %v", err)
+	}
+	if remaining != 1 {
+		t.Fatalf("Remaining() = %v, want %v", remaining, 1)
+	}
+}
+
+func TestSlidingWindowReserve(t *testing.T) {
+	cache, clock := setup(t, "test_ratelimit_sliding_window_reserve.csv")
+	limiter := newSlidingWindow(t, cache, SlidingWindowConfig{Limit: 1, Window: 5 * time.Second})
+	limiter.now = clock.Now
+	ctx := context.Background()
+
+	delays := []time.Duration{0, 5 * time.Second, 10 * time.Second}
+	for i, want := range delays {
+		reservation, err := limiter.Reserve(ctx, "user")
+		if err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+		if reservation.Delay != want {
+			t.Fatalf("Reserve() #%d delay = %v, want %v", i, reservation.Delay, want)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, "user")
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("Allow() should respect events reserved in the future")
+	}
+}
+
+func TestLimitersRejectInvalidCounts(t *testing.T) {
+	cache, _ := setup(t, "test_ratelimit_invalid.csv")
+	ctx := context.Background()
+
+	if _, err := NewTokenBucket(cache, TokenBucketConfig{Rate: 0, Burst: 2}); err == nil {
+		t.Fatal("NewTokenBucket() should reject a zero rate")
+	}
+
+	for _, cfg := range []SlidingWindowConfig{{Limit: 0, Window: time.Second}, {Limit: 2, Window: 0}, {Limit: -1, Window: -time.Second}} {
+		if _, err := NewSlidingWindow(cache, cfg); err == nil {
+			t.Fatalf("NewSlidingWindow(%+v) should reject the config", cfg)
+		}
+	}
+
+	bucket := newTokenBucket(t, cache, TokenBucketConfig{Rate: 1, Burst: 2})
+	window := newSlidingWindow(t, cache, SlidingWindowConfig{Limit: 2, Window: time.Second})
+	for _, limiter := range []Limiter{bucket, window} {
+		for _, n := range []int{0, -100} {
+			if _, err := limiter.AllowN(ctx, "user", n); err != ErrInvalidCount {
+				t.Fatalf("%T.AllowN(%d) error = %v, want %v", limiter, n, err, ErrInvalidCount)
+			}
+			if _, err := limiter.ReserveN(ctx, "user", n); err != ErrInvalidCount {
+				t.Fatalf("%T.ReserveN(%d) error = %v, want %v", limiter, n, err, ErrInvalidCount)
+			}
+		}
+	}
+
+	if remaining, err := bucket.Remaining(ctx, "user"); err != nil || remaining != 2 {
+		t.Fatalf("Remaining() = %d, %v, want the untouched burst", remaining, err)
+	}
+}
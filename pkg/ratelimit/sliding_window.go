@@ -0,0 +1,216 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pkg "own-database-cache/pkg/cache"
+)
+
+type SlidingWindowConfig struct {
+	Limit  int
+	Window time.Duration
+	Prefix string
+}
+
+// SlidingWindow admits at most Limit events in any Window. It stores the timestamps of the
+// admitted events (including reserved ones in the future) per key in the cache.
+type SlidingWindow struct {
+	cache *pkg.Cache
+	cfg   SlidingWindowConfig
+	now   func() time.Time
+}
+
+func NewSlidingWindow(cache *pkg.Cache, cfg SlidingWindowConfig) (*SlidingWindow, error) {
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("sliding window limit must be positive, got %d", cfg.Limit)
+	}
+	if cfg.Window <= 0 {
+		return nil, fmt.Errorf("sliding window must be positive, got %v", cfg.Window)
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = pkg.InternalPrefix + "ratelimit:sw:"
+	}
+	return &SlidingWindow{
+		cache: cache,
+		cfg:   cfg,
+		now:   time.Now,
+	}, nil
+}
+
+func (w *SlidingWindow) Allow(ctx context.Context, key string) (Result, error) {
+	return w.AllowN(ctx, key, 1)
+}
+
+func (w *SlidingWindow) AllowN(ctx context.Context, key string, n int) (Result, error) {
+	if n < 1 {
+		return Result{}, ErrInvalidCount
+	}
+	if n > w.cfg.Limit {
+		return Result{}, ErrExceedsLimit
+	}
+
+	var result Result
+	err := w.cache.Update(ctx, w.cfg.Prefix+key, func(value string, found bool) (string, time.Duration, error) {
+		now := w.now()
+		events, err := w.events(value, found, now)
+		if err != nil {
+			return "", 0, err
+		}
+
+		result = Result{Limit: w.cfg.Limit}
+		if at := w.earliest(events, n, now); at.After(now) {
+			result.RetryAfter = at.Sub(now)
+		} else {
+			events = insertEvents(events, now, n)
+			result.Allowed = true
+		}
+		result.Remaining = w.remaining(events)
+		result.ResetAfter = w.resetAfter(events, now)
+
+		return encodeEvents(events), stateTTL(result.ResetAfter), nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+func (w *SlidingWindow) Reserve(ctx context.Context, key string) (Reservation, error) {
+	return w.ReserveN(ctx, key, 1)
+}
+
+func (w *SlidingWindow) ReserveN(ctx context.Context, key string, n int) (Reservation, error) {
+	if n < 1 {
+		return Reservation{}, ErrInvalidCount
+	}
+	if n > w.cfg.Limit {
+		return Reservation{}, ErrExceedsLimit
+	}
+
+	var reservation Reservation
+	err := w.cache.Update(ctx, w.cfg.Prefix+key, func(value string, found bool) (string, time.Duration, error) {
+		now := w.now()
+		events, err := w.events(value, found, now)
+		if err != nil {
+			return "", 0, err
+		}
+
+		at := w.earliest(events, n, now)
+		events = insertEvents(events, at, n)
+		reservation = Reservation{
+			Delay:     at.Sub(now),
+			Limit:     w.cfg.Limit,
+			Remaining: w.remaining(events),
+		}
+
+		return encodeEvents(events), stateTTL(w.resetAfter(events, now)), nil
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+func (w *SlidingWindow) Remaining(ctx context.Context, key string) (int, error) {
+	value, found, err := w.cache.Get(ctx, w.cfg.Prefix+key)
+	if err != nil {
+		return 0, err
+	}
+
+	events, err := w.events(value, found, w.now())
+	if err != nil {
+		return 0, err
+	}
+
+	return w.remaining(events), nil
+}
+
+// events returns the sorted timestamps that still fall into the window ending at now or later.
+func (w *SlidingWindow) events(value string, found bool, now time.Time) ([]int64, error) {
+	if !found || value == "" {
+		return nil, nil
+	}
+
+	events, err := decodeEvents(value)
+	if err != nil {
+		return nil, err
+	}
+
+	start := now.Add(-w.cfg.Window).UnixNano()
+	first := sort.Search(len(events), func(i int) bool {
+		return events[i] > start
+	})
+
+	return events[first:], nil
+}
+
+// earliest returns the first moment n more events fit into the window without
+// exceeding the limit, taking already reserved events into account.
+func (w *SlidingWindow) earliest(events []int64, n int, now time.Time) time.Time {
+	overflow := len(events) + n - w.cfg.Limit
+	if overflow <= 0 {
+		return now
+	}
+
+	at := time.Unix(0, events[overflow-1]).Add(w.cfg.Window)
+	if at.Before(now) {
+		return now
+	}
+	return at
+}
+
+func (w *SlidingWindow) remaining(events []int64) int {
+	if remaining := w.cfg.Limit - len(events); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func (w *SlidingWindow) resetAfter(events []int64, now time.Time) time.Duration {
+	if len(events) == 0 {
+		return 0
+	}
+	return time.Unix(0, events[len(events)-1]).Add(w.cfg.Window).Sub(now)
+}
+
+func insertEvents(events []int64, at time.Time, n int) []int64 {
+	ts := at.UnixNano()
+	i := sort.Search(len(events), func(i int) bool {
+		return events[i] > ts
+	})
+
+	result := make([]int64, 0, len(events)+n)
+	result = append(result, events[:i]...)
+	for j := 0; j < n; j++ {
+		result = append(result, ts)
+	}
+	return append(result, events[i:]...)
+}
+
+func encodeEvents(events []int64) string {
+	parts := make([]string, len(events))
+	for i, event := range events {
+		parts[i] = strconv.FormatInt(event, 10)
+	}
+	return strings.Join(parts, ";")
+}
+
+func decodeEvents(value string) ([]int64, error) {
+	parts := strings.Split(value, ";")
+	events := make([]int64, len(parts))
+	for i, part := range parts {
+		event, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sliding window state %q: %w", value, err)
+		}
+		events[i] = event
+	}
+	return events, nil
+}
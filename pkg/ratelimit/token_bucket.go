@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	pkg "own-database-cache/pkg/cache"
+)
+
+type TokenBucketConfig struct {
+	// Rate is the number of tokens added to the bucket per second, must be positive.
+	Rate   float64
+	Burst  int
+	Prefix string
+}
+
+// TokenBucket keeps the bucket of every key in the cache, so the state survives restarts
+// and idle buckets disappear once they would have been refilled anyway.
+type TokenBucket struct {
+	cache *pkg.Cache
+	cfg   TokenBucketConfig
+	now   func() time.Time
+}
+
+func NewTokenBucket(cache *pkg.Cache, cfg TokenBucketConfig) (*TokenBucket, error) {
+	if cfg.Rate <= 0 || math.IsNaN(cfg.Rate) || math.IsInf(cfg.Rate, 0) {
+		return nil, fmt.Errorf("token bucket rate must be a positive number, got %v", cfg.Rate)
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = pkg.InternalPrefix + "ratelimit:tb:"
+	}
+	return &TokenBucket{
+		cache: cache,
+		cfg:   cfg,
+		now:   time.Now,
+	}, nil
+}
+
+func (b *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	return b.AllowN(ctx, key, 1)
+}
+
+func (b *TokenBucket) AllowN(ctx context.Context, key string, n int) (Result, error) {
+	if n < 1 {
+		return Result{}, ErrInvalidCount
+	}
+	if n > b.cfg.Burst {
+		return Result{}, ErrExceedsLimit
+	}
+
+	var result Result
+	err := b.cache.Update(ctx, b.cfg.Prefix+key, func(value string, found bool) (string, time.Duration, error) {
+		now := b.now()
+		tokens, err := b.tokens(value, found, now)
+		if err != nil {
+			return "", 0, err
+		}
+
+		result = Result{Limit: b.cfg.Burst}
+		if tokens >= float64(n) {
+			tokens -= float64(n)
+			result.Allowed = true
+		} else {
+			result.RetryAfter = b.wait(float64(n) - tokens)
+		}
+		result.Remaining = remainingTokens(tokens)
+		result.ResetAfter = b.wait(float64(b.cfg.Burst) - tokens)
+
+		return encodeBucket(tokens, now), stateTTL(result.ResetAfter), nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+func (b *TokenBucket) Reserve(ctx context.Context, key string) (Reservation, error) {
+	return b.ReserveN(ctx, key, 1)
+}
+
+func (b *TokenBucket) ReserveN(ctx context.Context, key string, n int) (Reservation, error) {
+	if n < 1 {
+		return Reservation{}, ErrInvalidCount
+	}
+	if n > b.cfg.Burst {
+		return Reservation{}, ErrExceedsLimit
+	}
+
+	var reservation Reservation
+	err := b.cache.Update(ctx, b.cfg.Prefix+key, func(value string, found bool) (string, time.Duration, error) {
+		now := b.now()
+		tokens, err := b.tokens(value, found, now)
+		if err != nil {
+			return "", 0, err
+		}
+
+		tokens -= float64(n)
+		reservation = Reservation{
+			Limit:     b.cfg.Burst,
+			Remaining: remainingTokens(tokens),
+		}
+		if tokens < 0 {
+			reservation.Delay = b.wait(-tokens)
+		}
+
+		return encodeBucket(tokens, now), stateTTL(b.wait(float64(b.cfg.Burst) - tokens)), nil
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+func (b *TokenBucket) Remaining(ctx context.Context, key string) (int, error) {
+	value, found, err := b.cache.Get(ctx, b.cfg.Prefix+key)
+	if err != nil {
+		return 0, err
+	}
+
+	tokens, err := b.tokens(value, found, b.now())
+	if err != nil {
+		return 0, err
+	}
+
+	return remainingTokens(tokens), nil
+}
+
+func (b *TokenBucket) tokens(value string, found bool, now time.Time) (float64, error) {
+	if !found {
+		return float64(b.cfg.Burst), nil
+	}
+
+	tokens, last, err := decodeBucket(value)
+	if err != nil {
+		return 0, err
+	}
+
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(b.cfg.Burst), tokens+elapsed*b.cfg.Rate), nil
+}
+
+func (b *TokenBucket) wait(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / b.cfg.Rate * float64(time.Second)))
+}
+
+func remainingTokens(tokens float64) int {
+	if tokens < 0 {
+		return 0
+	}
+	return int(tokens)
+}
+
+func encodeBucket(tokens float64, last time.Time) string {
+	return strconv.FormatFloat(tokens, 'g', -1, 64) + ";" + strconv.FormatInt(last.UnixNano(), 10)
+}
+
+func decodeBucket(value string) (float64, time.Time, error) {
+	parts := strings.Split(value, ";")
+	if len(parts) != 2 {
+		return 0, time.Time{}, fmt.Errorf("invalid token bucket state %q", value)
+	}
+
+	tokens, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid token bucket state %q: %w", value, err)
+	}
+	last, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid token bucket state %q: %w", value, err)
+	}
+
+	return tokens, time.Unix(0, last), nil
+}